@@ -1,7 +1,12 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -9,6 +14,9 @@ import (
 	"github.com/go-chi/cors"
 	"github.com/moabdelazem/social/internal/auth"
 	"github.com/moabdelazem/social/internal/mailer"
+	"github.com/moabdelazem/social/internal/outbox"
+	"github.com/moabdelazem/social/internal/ranking"
+	"github.com/moabdelazem/social/internal/ratelimit"
 	"github.com/moabdelazem/social/internal/store"
 	"go.uber.org/zap"
 )
@@ -19,16 +27,48 @@ type application struct {
 	logger        *zap.SugaredLogger
 	mailer        mailer.Client
 	authenticator auth.Authenticator
+	rateLimiter   ratelimit.Limiter
+	outbox        *outbox.Dispatcher
+	ranker        ranking.Ranker
+	wg            sync.WaitGroup
 }
 
 type config struct {
-	addr        string
-	env         string
-	frontendURL string
-	db          dbConfig
-	mail        mailConfig
-	auth        authConfig
-	cors        corsConfig
+	addr            string
+	env             string
+	shutdownTimeout time.Duration
+	frontendURL     string
+	db              dbConfig
+	mail            mailConfig
+	auth            authConfig
+	cors            corsConfig
+	rateLimit       rateLimitConfig
+	outbox          outbox.Config
+	feed            feedConfig
+	trash           trashConfig
+	scheduler       schedulerConfig
+}
+
+type feedConfig struct {
+	strategy          string        // default feed strategy, feedStrategyTimeline or feedStrategyRead
+	maxFanOut         int           // authors with more followers are not fanned out on write
+	rankingWindow     time.Duration // only posts this recent are ranked by sort=top
+	rankingCandidates int           // maximum number of posts ranked per request
+}
+
+type rateLimitConfig struct {
+	enabled bool
+	auth    ratelimit.Config
+	api     ratelimit.Config
+}
+
+type trashConfig struct {
+	retention     time.Duration // deleted posts are purged once they are this old
+	purgeInterval time.Duration
+}
+
+type schedulerConfig struct {
+	interval time.Duration // how often due scheduled posts are published
 }
 
 type corsConfig struct {
@@ -40,18 +80,23 @@ type authConfig struct {
 }
 
 type tokenConfig struct {
-	secret string
-	exp    time.Duration
-	iss    string
+	secret     string
+	exp        time.Duration
+	refreshExp time.Duration
+	iss        string
 }
 
 type mailConfig struct {
-	smtpHost  string
-	smtpPort  int
-	smtpUser  string
-	smtpPass  string
-	fromEmail string
-	exp       time.Duration
+	backend        string
+	sandboxBackend string
+	fileDir        string
+	smtpHost       string
+	smtpPort       int
+	smtpUser       string
+	smtpPass       string
+	fromEmail      string
+	exp            time.Duration
+	resetExp       time.Duration
 }
 
 type dbConfig struct {
@@ -75,8 +120,8 @@ func (app *application) mount() http.Handler {
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   app.config.cors.allowedOrigins,
 		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "If-Match", "If-None-Match"},
+		ExposedHeaders:   []string{"ETag", "Link", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"},
 		AllowCredentials: true,
 		MaxAge:           300, // Maximum value not ignored by any of major browsers
 	}))
@@ -98,49 +143,202 @@ func (app *application) mount() http.Handler {
 		// Posts Route Group
 		r.Route("/posts", func(r chi.Router) {
 			r.Use(app.AuthTokenMiddleware)
-			r.Post("/", app.createPostHandler)
+			r.Use(app.RateLimitMiddleware("api", app.config.rateLimit.api))
+			r.With(app.RequirePermission("posts:create")).Post("/", app.createPostHandler)
+
+			// Posts in the trash are only reachable to be restored
+			r.With(app.trashedPostsContextMiddleware).Post("/{postID}/restore", app.checkPostOwnership("posts:delete:any", app.restorePostHandler))
 
 			r.Route("/{postID}", func(r chi.Router) {
 				r.Use(app.postsContextMiddleware)
 
 				r.Get("/", app.getPostHandler)
-				r.Delete("/", app.deletePostHandler)
-				r.Patch("/", app.updatePostHandler)
+				r.Delete("/", app.checkPostOwnership("posts:delete:any", app.deletePostHandler))
+				r.Patch("/", app.checkPostOwnership("posts:update:any", app.updatePostHandler))
+
+				r.Get("/comments", app.listCommentsHandler)
+				r.Post("/comments", app.createCommentHandler)
+
+				r.Put("/reactions/{kind}", app.addReactionHandler)
+				r.Delete("/reactions/{kind}", app.removeReactionHandler)
+
+				r.Get("/revisions", app.listRevisionsHandler)
+				r.Get("/revisions/diff", app.diffRevisionsHandler)
+				r.Get("/revisions/{version}", app.getRevisionHandler)
+				r.Post("/revisions/{version}/restore", app.checkPostOwnership("posts:update:any", app.restoreRevisionHandler))
 			})
 		})
 
+		// Comments Route Group
+		r.Route("/comments/{commentID}", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Use(app.RateLimitMiddleware("api", app.config.rateLimit.api))
+			r.Use(app.commentsContextMiddleware)
+
+			r.Patch("/", app.checkCommentOwnership("comments:update:any", app.updateCommentHandler))
+			r.Delete("/", app.checkCommentOwnership("comments:delete:any", app.deleteCommentHandler))
+		})
+
 		// Users Route Group
 		r.Route("/users", func(r chi.Router) {
 			r.Route("/{userID}", func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleware)
+				r.Use(app.RateLimitMiddleware("api", app.config.rateLimit.api))
 				r.Use(app.usersContextMiddleware)
 
 				r.Get("/", app.getUserHandler)
 				r.Put("/follow", app.followUserHandler)
 				r.Put("/unfollow", app.unfollowUserHandler)
+				r.Get("/posts", app.getTargetUserPostsHandler)
+				r.Get("/followers", app.listFollowersHandler)
+				r.Get("/following", app.listFollowingHandler)
+				r.Put("/block", app.blockUserHandler)
+				r.Delete("/block", app.unblockUserHandler)
+				r.Put("/mute", app.muteUserHandler)
+				r.Delete("/mute", app.unmuteUserHandler)
 			})
 
 			r.Group(func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleware)
+				r.Use(app.RateLimitMiddleware("api", app.config.rateLimit.api))
 				r.Get("/feed", app.getUserFeedHandler)
+				r.Get("/me", app.getCurrentUserHandler)
+				r.Patch("/me", app.updateProfileHandler)
 				r.Get("/me/posts", app.getUserPostsHandler)
+				r.Get("/me/trash", app.listTrashHandler)
+				r.Get("/me/drafts", app.listDraftsHandler)
+				r.Get("/me/follow-requests", app.listFollowRequestsHandler)
+				r.Put("/me/follow-requests/{userID}", app.approveFollowRequestHandler)
+				r.Delete("/me/follow-requests/{userID}", app.rejectFollowRequestHandler)
+				r.Get("/by-username/{username}", app.getUserByUsernameHandler)
 			})
 		})
 
+		// Search Route
+		r.With(
+			app.AuthTokenMiddleware,
+			app.RateLimitMiddleware("api", app.config.rateLimit.api),
+		).Get("/search", app.searchHandler)
+
+		// Admin Route Group
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Use(app.RateLimitMiddleware("api", app.config.rateLimit.api))
+
+			r.With(app.RequirePermission("outbox:read")).Get("/outbox/failed", app.listFailedEmailsHandler)
+		})
+
 		r.Route("/auth", func(r chi.Router) {
+			r.Use(app.RateLimitMiddleware("auth", app.config.rateLimit.auth))
+
 			r.Post("/register", app.registerUserHandler)
 			r.Put("/activate", app.activateUserHandler)
 			r.Post("/login", app.loginUserHandler)
+			r.Post("/refresh", app.refreshTokenHandler)
+			r.Post("/logout", app.logoutUserHandler)
+
+			r.Route("/password", func(r chi.Router) {
+				r.Post("/forgot", app.forgotPasswordHandler)
+				r.Post("/reset", app.resetPasswordHandler)
+			})
 		})
 	})
 
 	return r
 }
 
-func (a *application) Run() error {
-	r := &http.Server{
-		Addr:    a.config.addr,
-		Handler: a.mount(),
+// Run serves the API until SIGINT or SIGTERM is received, then stops accepting
+// connections and waits for in-flight requests and background tasks to finish
+// within the configured shutdown timeout.
+func (app *application) Run() error {
+	srv := &http.Server{
+		Addr:         app.config.addr,
+		Handler:      app.mount(),
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 75 * time.Second, // Must outlive the request timeout middleware
+		IdleTimeout:  time.Minute,
 	}
-	return r.ListenAndServe()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Deliver queued emails until shutdown
+	app.background(func() {
+		app.outbox.Run(ctx)
+	})
+
+	// Permanently delete posts left in the trash past the retention period
+	app.background(func() {
+		app.purgeTrash(ctx)
+	})
+
+	// Publish scheduled posts when they are due
+	app.background(func() {
+		app.publishScheduledPosts(ctx)
+	})
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		return err
+	case <-ctx.Done():
+	}
+	stop()
+
+	app.logger.Infow("Shutting down server",
+		"timeout", app.config.shutdownTimeout.String(),
+	)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), app.config.shutdownTimeout)
+	defer cancel()
+
+	// Background tasks are drained even if some requests could not be
+	shutdownErr := srv.Shutdown(shutdownCtx)
+
+	app.logger.Info("Waiting for background tasks to finish")
+
+	if err := errors.Join(shutdownErr, app.waitBackground(shutdownCtx)); err != nil {
+		return err
+	}
+
+	app.logger.Info("Server stopped")
+
+	return nil
+}
+
+// waitBackground waits for the tasks started with background, until ctx is done
+func (app *application) waitBackground(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		app.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errors.New("timed out waiting for background tasks")
+	}
+}
+
+// background runs fn in a goroutine that Run waits for before returning
+func (app *application) background(fn func()) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.Errorw("Background task panicked", "error", err)
+			}
+		}()
+
+		fn()
+	}()
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
@@ -24,9 +25,11 @@ type ActivateUserPayload struct {
 	Token string `json:"token" validate:"required"`
 }
 
+// UserWithToken is the register response. The activation token is only
+// included in development, elsewhere it is only sent by email.
 type UserWithToken struct {
-	*store.User
-	Token string `json:"token"`
+	privateUser
+	Token string `json:"token,omitempty"`
 }
 
 type CreateTokenPayload struct {
@@ -34,6 +37,19 @@ type CreateTokenPayload struct {
 	Password string `json:"password" validate:"required,min=3,max=73"`
 }
 
+type RefreshTokenPayload struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type ForgotPasswordPayload struct {
+	Email string `json:"email" validate:"required,email,max=100"`
+}
+
+type ResetPasswordPayload struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=3,max=72"`
+}
+
 func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Request) {
 	var payload RegisterUserPayload
 	if err := readJSON(w, r, &payload); err != nil {
@@ -62,51 +78,41 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 	ctx := r.Context()
 
 	plainToken := uuid.New().String()
-
-	hash := sha256.Sum256([]byte(plainToken))
-	hashToken := hex.EncodeToString(hash[:])
+	hashedToken := hashToken(plainToken)
 
 	// Set invitation expiry from config
 	expiry := time.Now().Add(app.config.mail.exp)
 
-	if err := app.store.UsersRepo.CreateAndInvite(ctx, user, hashToken, expiry); err != nil {
-		switch {
-		case errors.Is(err, store.ErrorConflict):
-			app.conflictResponse(w, r, err)
-		default:
-			app.internalServerError(w, r, err)
-		}
-		return
-	}
-
-	// Send activation email
+	// Queue the activation email together with the new user
 	activationURL := fmt.Sprintf("%s/activate?token=%s", app.config.frontendURL, plainToken)
 
-	emailData := mailer.EmailData{
+	email, err := newOutboxMessage("Activate Your Account", "user_invitation", mailer.EmailData{
 		Username:      user.Username,
 		ActivationURL: activationURL,
 		ExpiryTime:    expiry,
 		AppName:       "Social API",
+		Locale:        requestLocale(r),
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
 	}
 
-	// Send email in background
-	go func() {
-		if _, err := app.mailer.Send(user.Email, "Activate Your Account", "user_invitation", emailData, false); err != nil {
-			app.logger.Errorw("Failed to send activation email",
-				"error", err,
-				"email", user.Email,
-			)
-		} else {
-			app.logger.Infow("Activation email sent",
-				"email", user.Email,
-				"username", user.Username,
-			)
+	if err := app.store.UsersRepo.CreateAndInvite(ctx, user, hashedToken, expiry, email); err != nil {
+		switch {
+		case errors.Is(err, store.ErrorConflict):
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
 		}
-	}()
+		return
+	}
 
 	userWithToken := UserWithToken{
-		User:  user,
-		Token: plainToken,
+		privateUser: newPrivateUser(user),
+	}
+	if app.config.env == "development" {
+		userWithToken.Token = plainToken
 	}
 
 	app.logger.Infow("User registered",
@@ -154,16 +160,7 @@ func (app *application) loginUserHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	claims := jwt.MapClaims{
-		"sub": user.ID,
-		"exp": time.Now().Add(app.config.auth.token.exp).Unix(),
-		"iat": time.Now().Unix(),
-		"nbf": time.Now().Unix(),
-		"iss": app.config.auth.token.iss,
-		"aud": app.config.auth.token.iss,
-	}
-
-	token, err := app.authenticator.GenerateToken(claims)
+	token, refreshToken, err := app.issueTokens(ctx, user, uuid.New().String())
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -175,10 +172,69 @@ func (app *application) loginUserHandler(w http.ResponseWriter, r *http.Request)
 		"email", user.Email,
 	)
 
-	// Return user data with token
+	// Return user data with tokens
+	response := map[string]interface{}{
+		"token":         token,
+		"refresh_token": refreshToken,
+		"user":          newPrivateUser(user),
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var payload RefreshTokenPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Validate the request payload
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	plainRefresh := uuid.New().String()
+	next := &store.RefreshToken{
+		Token:  hashToken(plainRefresh),
+		Expiry: time.Now().Add(app.config.auth.token.refreshExp),
+	}
+
+	if err := app.store.RefreshRepo.Rotate(ctx, hashToken(payload.RefreshToken), next); err != nil {
+		switch {
+		case errors.Is(err, store.ErrorTokenReused):
+			app.logger.Warnw("Refresh token reuse detected, token family revoked")
+			app.unauthorizedErrorResponse(w, r, errors.New("invalid refresh token"))
+		case errors.Is(err, store.ErrorNotFound):
+			app.unauthorizedErrorResponse(w, r, errors.New("invalid refresh token"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	user, err := app.getUser(ctx, next.UserID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	token, err := app.generateAccessToken(user)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
 	response := map[string]interface{}{
-		"token": token,
-		"user":  user,
+		"token":         token,
+		"refresh_token": plainRefresh,
+		"user":          newPrivateUser(user),
 	}
 
 	if err := app.jsonResponse(w, http.StatusOK, response); err != nil {
@@ -187,6 +243,180 @@ func (app *application) loginUserHandler(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+func (app *application) logoutUserHandler(w http.ResponseWriter, r *http.Request) {
+	var payload RefreshTokenPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Validate the request payload
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := app.store.RefreshRepo.Revoke(ctx, hashToken(payload.RefreshToken)); err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.unauthorizedErrorResponse(w, r, errors.New("invalid refresh token"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) forgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ForgotPasswordPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Validate the request payload
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// The response is the same whether or not the email is registered
+	response := map[string]string{
+		"message": "If an account with that email exists, a password reset link has been sent",
+	}
+
+	ctx := r.Context()
+
+	user, err := app.store.UsersRepo.GetByEmail(ctx, payload.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			if err := app.jsonResponse(w, http.StatusAccepted, response); err != nil {
+				app.internalServerError(w, r, err)
+			}
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	plainToken := uuid.New().String()
+	expiry := time.Now().Add(app.config.mail.resetExp)
+
+	email, err := newOutboxMessage("Reset Your Password", "password_reset", mailer.EmailData{
+		Username:   user.Username,
+		ResetURL:   fmt.Sprintf("%s/reset-password?token=%s", app.config.frontendURL, plainToken),
+		ExpiryTime: expiry,
+		AppName:    "Social API",
+		Locale:     requestLocale(r),
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.UsersRepo.CreatePasswordReset(ctx, user, hashToken(plainToken), expiry, email); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusAccepted, response); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ResetPasswordPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Validate the request payload
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	var password store.Password
+	if err := password.Set(payload.Password); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := app.store.UsersRepo.ResetPassword(ctx, payload.Token, &password); err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.logger.Info("Password reset successfully")
+
+	if err := app.jsonResponse(w, http.StatusOK, map[string]string{
+		"message": "Password reset successfully",
+	}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// issueTokens creates a short-lived access token and a new refresh token
+// belonging to the given token family.
+func (app *application) issueTokens(ctx context.Context, user *store.User, familyID string) (string, string, error) {
+	token, err := app.generateAccessToken(user)
+	if err != nil {
+		return "", "", err
+	}
+
+	plainRefresh := uuid.New().String()
+	refreshToken := &store.RefreshToken{
+		Token:    hashToken(plainRefresh),
+		UserID:   user.ID,
+		FamilyID: familyID,
+		Expiry:   time.Now().Add(app.config.auth.token.refreshExp),
+	}
+
+	if err := app.store.RefreshRepo.Create(ctx, refreshToken); err != nil {
+		return "", "", err
+	}
+
+	return token, plainRefresh, nil
+}
+
+// generateAccessToken signs a JWT for the user. The role claim is informational;
+// permissions are always enforced against the role stored in the database.
+func (app *application) generateAccessToken(user *store.User) (string, error) {
+	claims := jwt.MapClaims{
+		"sub":  user.ID,
+		"tv":   user.TokenVersion,
+		"role": user.Role.Name,
+		"exp":  time.Now().Add(app.config.auth.token.exp).Unix(),
+		"iat":  time.Now().Unix(),
+		"nbf":  time.Now().Unix(),
+		"iss":  app.config.auth.token.iss,
+		"aud":  app.config.auth.token.iss,
+	}
+
+	return app.authenticator.GenerateToken(claims)
+}
+
+// hashToken returns the hex encoded SHA-256 hash of a plain token
+func hashToken(plainToken string) string {
+	hash := sha256.Sum256([]byte(plainToken))
+	return hex.EncodeToString(hash[:])
+}
+
 func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Request) {
 	var payload ActivateUserPayload
 	if err := readJSON(w, r, &payload); err != nil {
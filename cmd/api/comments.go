@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/moabdelazem/social/internal/store"
+)
+
+type CreateCommentPayload struct {
+	Content string `json:"content" validate:"required,max=1000"`
+}
+
+type UpdateCommentPayload struct {
+	Content string `json:"content" validate:"required,max=1000"`
+	Version *int   `json:"version"` // optional, the version the client edited
+}
+
+func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateCommentPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Validate the request payload
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+	post := getPostFromCtx(r)
+
+	if !app.canViewPost(w, r, post) {
+		return
+	}
+
+	comment := &store.Comment{
+		PostID:  post.ID,
+		UserID:  user.ID,
+		Content: payload.Content,
+		User: store.User{
+			ID:          user.ID,
+			Username:    user.Username,
+			DisplayName: user.DisplayName,
+			AvatarURL:   user.AvatarURL,
+		},
+	}
+
+	ctx := r.Context()
+	if err := app.store.CommentRepo.Create(ctx, comment); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.logger.Infow("Comment created",
+		"comment_id", comment.ID,
+		"post_id", comment.PostID,
+		"user_id", comment.UserID,
+	)
+
+	if err := app.jsonResponse(w, http.StatusCreated, newCommentView(comment)); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) listCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse pagination query parameters
+	cq := store.PaginatedCursorQuery{}
+	cq, err := cq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Validate pagination parameters
+	if err := Validate.Struct(cq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	post := getPostFromCtx(r)
+	viewer := getUserFromCtx(r)
+
+	if !app.canViewPost(w, r, post) {
+		return
+	}
+
+	ctx := r.Context()
+	comments, next, err := app.store.CommentRepo.ListByPostID(ctx, post.ID, viewer.ID, cq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.paginatedResponse(w, r, http.StatusOK, newCommentViews(comments), cq.Page(store.PageCursors{Next: next})); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) updateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment := getCommentFromCtx(r)
+
+	var payload UpdateCommentPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if !app.checkIfMatch(w, r, comment.Version) {
+		return
+	}
+
+	if payload.Version != nil && *payload.Version != comment.Version {
+		app.editConflictResponse(w, r, store.ErrorEditConflict)
+		return
+	}
+
+	comment.Content = payload.Content
+
+	ctx := r.Context()
+	if err := app.store.CommentRepo.Update(ctx, comment); err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.versionConflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.logger.Infow("Comment updated",
+		"comment_id", comment.ID,
+		"user_id", comment.UserID,
+		"version", comment.Version,
+	)
+
+	w.Header().Set("ETag", versionETag(comment.Version))
+	if err := app.jsonResponse(w, http.StatusOK, newCommentView(comment)); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) deleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment := getCommentFromCtx(r)
+
+	ctx := r.Context()
+	if err := app.store.CommentRepo.Delete(ctx, comment.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.logger.Infow("Comment deleted",
+		"comment_id", comment.ID,
+		"post_id", comment.PostID,
+		"user_id", comment.UserID,
+	)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) commentsContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		commentID, err := strconv.ParseInt(chi.URLParam(r, "commentID"), 10, 64)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		ctx := r.Context()
+		comment, err := app.store.CommentRepo.GetByID(ctx, commentID)
+
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrorNotFound):
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		ctx = context.WithValue(ctx, "comment", comment)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getCommentFromCtx(r *http.Request) *store.Comment {
+	comment, _ := r.Context().Value("comment").(*store.Comment)
+	return comment
+}
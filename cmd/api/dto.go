@@ -0,0 +1,142 @@
+package main
+
+import (
+	"time"
+
+	"github.com/moabdelazem/social/internal/store"
+)
+
+// publicUser is what anyone can see of a user
+type publicUser struct {
+	ID          int64  `json:"id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	Bio         string `json:"bio"`
+	AvatarURL   string `json:"avatar_url"`
+	IsPrivate   bool   `json:"is_private"`
+	CreatedAt   string `json:"created_at"`
+
+	// Only set on profile reads
+	*store.FollowCounts
+	Relationship *store.Relationship `json:"relationship,omitempty"`
+}
+
+// privateUser is the authenticated user's view of their own account
+type privateUser struct {
+	publicUser
+	Email       string   `json:"email"`
+	IsActive    bool     `json:"is_active"`
+	Role        string   `json:"role"`
+	Permissions []string `json:"permissions"`
+	Version     int      `json:"version"`
+	UpdatedAt   string   `json:"updated_at"`
+}
+
+func newPublicUser(user *store.User) publicUser {
+	return publicUser{
+		ID:          user.ID,
+		Username:    user.Username,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		AvatarURL:   user.AvatarURL,
+		IsPrivate:   user.IsPrivate,
+		CreatedAt:   user.CreatedAt,
+	}
+}
+
+func newPrivateUser(user *store.User) privateUser {
+	permissions := user.Role.Permissions
+	if permissions == nil {
+		permissions = []string{}
+	}
+
+	return privateUser{
+		publicUser:  newPublicUser(user),
+		Email:       user.Email,
+		IsActive:    user.IsActive,
+		Role:        user.Role.Name,
+		Permissions: permissions,
+		Version:     user.Version,
+		UpdatedAt:   user.UpdatedAt,
+	}
+}
+
+// newUserView returns the private view of user when viewer is that user, and
+// the public view, with the viewer's relationship to user, otherwise
+func newUserView(viewer, user *store.User, counts *store.FollowCounts, rel *store.Relationship) any {
+	if viewer != nil && viewer.ID == user.ID {
+		view := newPrivateUser(user)
+		view.FollowCounts = counts
+		return view
+	}
+
+	view := newPublicUser(user)
+	view.FollowCounts = counts
+	view.Relationship = rel
+	return view
+}
+
+// followEntry is a user in a followers or following list
+type followEntry struct {
+	publicUser
+	FollowedAt     time.Time `json:"followed_at"`
+	IsFollowedByMe bool      `json:"is_followed_by_me"`
+}
+
+func newFollowEntries(entries []store.FollowEntry) []followEntry {
+	views := make([]followEntry, len(entries))
+	for i, e := range entries {
+		views[i] = followEntry{
+			publicUser:     newPublicUser(&e.User),
+			FollowedAt:     e.FollowedAt,
+			IsFollowedByMe: e.IsFollowedByMe,
+		}
+	}
+	return views
+}
+
+// commentAuthor is what is shown of the author next to a comment
+type commentAuthor struct {
+	ID          int64  `json:"id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	AvatarURL   string `json:"avatar_url"`
+}
+
+// commentView is a comment along with its author
+type commentView struct {
+	ID        int64         `json:"id"`
+	Content   string        `json:"content"`
+	UserID    int64         `json:"user_id"`
+	PostID    int64         `json:"post_id"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+	Version   int           `json:"version"`
+	User      commentAuthor `json:"user"`
+}
+
+func newCommentView(c *store.Comment) commentView {
+	return commentView{
+		ID:        c.ID,
+		Content:   c.Content,
+		UserID:    c.UserID,
+		PostID:    c.PostID,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+		Version:   c.Version,
+		User: commentAuthor{
+			ID:          c.User.ID,
+			Username:    c.User.Username,
+			DisplayName: c.User.DisplayName,
+			AvatarURL:   c.User.AvatarURL,
+		},
+	}
+}
+
+func newCommentViews(comments []store.Comment) []commentView {
+	views := make([]commentView, len(comments))
+	for i := range comments {
+		views[i] = newCommentView(&comments[i])
+	}
+	return views
+}
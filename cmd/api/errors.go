@@ -40,6 +40,24 @@ func (app *application) conflictResponse(w http.ResponseWriter, r *http.Request,
 	writeJSONError(w, http.StatusConflict, err.Error())
 }
 
+func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Warnw("Edit conflict",
+		"error", err.Error(),
+		"path", r.URL.Path,
+		"method", r.Method,
+	)
+	writeJSONError(w, http.StatusConflict, "unable to update the record due to an edit conflict, please try again")
+}
+
+func (app *application) preconditionFailedResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Warnw("Precondition failed",
+		"error", err.Error(),
+		"path", r.URL.Path,
+		"method", r.Method,
+	)
+	writeJSONError(w, http.StatusPreconditionFailed, "the resource was modified, fetch it again before retrying")
+}
+
 func (app *application) unauthorizedErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
 	app.logger.Warnw("Unauthorized",
 		"error", err.Error(),
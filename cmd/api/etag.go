@@ -0,0 +1,98 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var errPreconditionFailed = errors.New("the resource was modified since it was read")
+
+// versionETag returns the entity tag of a resource at version. Posts carry
+// their version in a strong ETag so that clients can send it back in If-Match.
+func versionETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// contentETag returns the entity tag of a representation of a resource at
+// version that also embeds other data, such as a post with its comments and
+// reactions. The tag changes whenever the encoded body does, while its
+// version prefix still works in If-Match.
+func contentETag(version int, body any) (string, error) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+	return `"` + strconv.Itoa(version) + "-" + hex.EncodeToString(sum[:8]) + `"`, nil
+}
+
+// etagVersion returns the version a tag built by versionETag or contentETag
+// was derived from
+func etagVersion(tag string) string {
+	version, _, _ := strings.Cut(strings.Trim(tag, `"`), "-")
+	return version
+}
+
+// listedETags splits the value of an If-Match or If-None-Match header
+func listedETags(header string) []string {
+	tags := strings.Split(header, ",")
+	for i := range tags {
+		tags[i] = strings.TrimSpace(tags[i])
+	}
+	return tags
+}
+
+// checkIfMatch evaluates the If-Match precondition of r against the current
+// version of the resource, responding with 412 when it fails. Tags match on
+// their version so that edits are not refused because of new comments or
+// reactions. Weak tags never match, as required for If-Match.
+func (app *application) checkIfMatch(w http.ResponseWriter, r *http.Request, version int) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return true
+	}
+
+	for _, tag := range listedETags(header) {
+		if tag == "*" || (!strings.HasPrefix(tag, "W/") && etagVersion(tag) == strconv.Itoa(version)) {
+			return true
+		}
+	}
+
+	app.preconditionFailedResponse(w, r, errPreconditionFailed)
+	return false
+}
+
+// notModified evaluates the If-None-Match precondition of a GET request
+// against the etag of the response, responding with 304 when the client
+// already holds it. Comparison is weak, as required for If-None-Match.
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+
+	for _, tag := range listedETags(header) {
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// versionConflictResponse reports that the resource changed between the
+// moment it was read and the write: 412 when the client made the write
+// conditional with If-Match, 409 otherwise
+func (app *application) versionConflictResponse(w http.ResponseWriter, r *http.Request, err error) {
+	if r.Header.Get("If-Match") != "" {
+		app.preconditionFailedResponse(w, r, err)
+		return
+	}
+	app.editConflictResponse(w, r, err)
+}
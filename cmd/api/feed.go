@@ -1,11 +1,27 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"net/http"
+	"sort"
+	"time"
 
+	"github.com/moabdelazem/social/internal/ranking"
 	"github.com/moabdelazem/social/internal/store"
 )
 
+const (
+	// feedStrategyTimeline reads the materialized timeline (fan-out-on-write)
+	feedStrategyTimeline = "timeline"
+	// feedStrategyRead joins the followed users' posts at read time (fan-out-on-read)
+	feedStrategyRead = "read"
+
+	// affinityWindow is how far back the viewer's interactions with an author
+	// count towards ranking that author's posts
+	affinityWindow = 90 * 24 * time.Hour
+)
+
 func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Request) {
 	// Parse pagination query parameters
 	fq := store.PaginatedFeedQuery{}
@@ -26,13 +42,136 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 
 	ctx := r.Context()
 
-	feed, err := app.store.PostsRepo.GetUserFeed(ctx, user.ID, fq)
+	// Pick the feed strategy, defaulting to the configured one
+	strategy := r.URL.Query().Get("strategy")
+	if strategy == "" {
+		strategy = app.config.feed.strategy
+	}
+
+	var fetch feedFetcher
+	switch strategy {
+	case feedStrategyTimeline:
+		fetch = app.store.TimelineRepo.GetFeed
+	case feedStrategyRead:
+		fetch = app.store.PostsRepo.GetUserFeed
+	default:
+		app.badRequestResponse(w, r, fmt.Errorf("strategy must be %q or %q", feedStrategyTimeline, feedStrategyRead))
+		return
+	}
+
+	if fq.Sort == "top" {
+		debug := r.URL.Query().Get("debug") == "true"
+
+		feed, hasMore, err := app.rankFeed(ctx, user.ID, fq, fetch, debug)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		page := fq.Page(store.PageCursors{})
+		page.HasMore = hasMore
+
+		if err := app.paginatedResponse(w, r, http.StatusOK, feed, page); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	feed, cursors, err := fetch(ctx, user.ID, fq)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	if err := app.jsonResponse(w, http.StatusOK, feed); err != nil {
+	if err := app.paginatedResponse(w, r, http.StatusOK, feed, fq.Page(cursors)); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
+
+// feedFetcher loads a page of a user's feed with one of the feed strategies
+type feedFetcher func(context.Context, int64, store.PaginatedFeedQuery) ([]store.PostsWithMetaData, store.PageCursors, error)
+
+// rankedPost is a feed post ranked by sort=top. Score is only set on debug
+// requests.
+type rankedPost struct {
+	store.PostsWithMetaData
+	Score *ranking.Score `json:"score,omitempty"`
+}
+
+// rankFeed ranks the newest posts of the feed within the ranking window with
+// app.ranker and returns the page of fq.Limit posts at fq.Offset, along with
+// whether more ranked posts follow.
+func (app *application) rankFeed(ctx context.Context, userID int64, fq store.PaginatedFeedQuery, fetch feedFetcher, debug bool) ([]rankedPost, bool, error) {
+	now := time.Now()
+
+	// Candidates are the newest posts matching the filters within the window
+	cq := fq
+	cq.Sort = "desc"
+	cq.Offset = 0
+	cq.Limit = app.config.feed.rankingCandidates
+
+	windowStart := now.Add(-app.config.feed.rankingWindow)
+	if since, err := time.Parse(time.RFC3339, fq.Since); err != nil || since.Before(windowStart) {
+		cq.Since = windowStart.Format(time.RFC3339)
+	}
+
+	candidates, _, err := fetch(ctx, userID, cq)
+	if err != nil {
+		return nil, false, err
+	}
+
+	interactions, err := app.store.PostsRepo.GetAuthorInteractions(ctx, userID, now.Add(-affinityWindow))
+	if err != nil {
+		return nil, false, err
+	}
+
+	ranked := make([]rankedPost, len(candidates))
+	for i, post := range candidates {
+		score := app.ranker.Score(ranking.Signals{
+			CreatedAt:    post.CreatedAt,
+			Comments:     post.CommentsCount,
+			Reactions:    post.Reactions.Total(),
+			Interactions: interactions[post.UserID],
+		}, now)
+		ranked[i] = rankedPost{PostsWithMetaData: post, Score: &score}
+	}
+
+	// Candidates are sorted newest first, so ties stay chronological
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Score.Total > ranked[j].Score.Total
+	})
+
+	start := min(fq.Offset, len(ranked))
+	end := min(fq.Offset+fq.Limit, len(ranked))
+	page := ranked[start:end]
+
+	if !debug {
+		for i := range page {
+			page[i].Score = nil
+		}
+	}
+
+	return page, end < len(ranked), nil
+}
+
+// fanOutPost pushes a new post into its author's followers' timelines in the
+// background. Until it completes, or if the author has too many followers,
+// the post is merged into timelines at read time.
+func (app *application) fanOutPost(post *store.Post) {
+	app.background(func() {
+		fannedOut, err := app.store.TimelineRepo.FanOut(context.Background(), post, app.config.feed.maxFanOut)
+		if err != nil {
+			app.logger.Errorw("Failed to fan out post",
+				"error", err,
+				"post_id", post.ID,
+			)
+			return
+		}
+
+		app.logger.Infow("Post fanned out",
+			"post_id", post.ID,
+			"user_id", post.UserID,
+			"fanned_out", fannedOut,
+		)
+	})
+}
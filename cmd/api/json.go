@@ -2,9 +2,14 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/moabdelazem/social/internal/store"
 )
 
 var Validate *validator.Validate
@@ -42,3 +47,66 @@ func (app *application) jsonResponse(w http.ResponseWriter, status int, data any
 	}
 	return writeJSON(w, status, &envelope{Data: data})
 }
+
+// paginatedResponse writes a page of a listing along with its pagination
+// metadata, and links to the surrounding pages in the Link header.
+func (app *application) paginatedResponse(w http.ResponseWriter, r *http.Request, status int, data any, page store.Pagination) error {
+	setPaginationLinks(w, r, page)
+
+	type envelope struct {
+		Data       any              `json:"data"`
+		Pagination store.Pagination `json:"pagination"`
+	}
+	return writeJSON(w, status, &envelope{Data: data, Pagination: page})
+}
+
+// setPaginationLinks sets the Link header of a paginated response
+func setPaginationLinks(w http.ResponseWriter, r *http.Request, page store.Pagination) {
+	if links := paginationLinks(r, page); len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
+
+// paginationLinks returns RFC 8288 links to the first, previous and next
+// pages, relative to the request URL and keeping its other query parameters.
+func paginationLinks(r *http.Request, page store.Pagination) []string {
+	link := func(rel, param, value string) string {
+		qs := r.URL.Query()
+		qs.Del("cursor")
+		qs.Del("offset")
+		if param != "" {
+			qs.Set(param, value)
+		}
+
+		u := url.URL{Path: r.URL.Path, RawQuery: qs.Encode()}
+		return fmt.Sprintf(`<%s>; rel="%s"`, u.String(), rel)
+	}
+
+	links := []string{link("first", "", "")}
+
+	// Offset paginated page
+	if page.Offset != nil {
+		offset := *page.Offset
+		if offset > 0 {
+			prev := max(offset-page.Limit, 0)
+			if prev > 0 {
+				links = append(links, link("prev", "offset", strconv.Itoa(prev)))
+			} else {
+				links = append(links, link("prev", "", ""))
+			}
+		}
+		if page.HasMore {
+			links = append(links, link("next", "offset", strconv.Itoa(offset+page.Limit)))
+		}
+		return links
+	}
+
+	// Keyset paginated page
+	if page.Prev != "" {
+		links = append(links, link("prev", "cursor", page.Prev))
+	}
+	if page.Next != "" {
+		links = append(links, link("next", "cursor", page.Next))
+	}
+	return links
+}
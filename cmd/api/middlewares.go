@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
@@ -12,6 +14,7 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/moabdelazem/social/internal/ratelimit"
 	"github.com/moabdelazem/social/internal/store"
 )
 
@@ -36,16 +39,24 @@ func (app *application) usersContextMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		ctx = context.WithValue(ctx, "user", user)
+		// Stored apart from the authenticated user set by AuthTokenMiddleware
+		ctx = context.WithValue(ctx, "targetUser", user)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// getUserFromCtx returns the authenticated user
 func getUserFromCtx(r *http.Request) *store.User {
 	user, _ := r.Context().Value("user").(*store.User)
 	return user
 }
 
+// getTargetUserFromCtx returns the user addressed by the {userID} URL parameter
+func getTargetUserFromCtx(r *http.Request) *store.User {
+	user, _ := r.Context().Value("targetUser").(*store.User)
+	return user
+}
+
 func (app *application) zapLogger(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
@@ -109,11 +120,69 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		// Tokens issued before the last password reset are revoked. Tokens
+		// without the claim predate token versions and count as version 0.
+		tokenVersion, _ := claims["tv"].(float64)
+		if int(tokenVersion) != user.TokenVersion {
+			app.unauthorizedErrorResponse(w, r, fmt.Errorf("token has been revoked"))
+			return
+		}
+
 		ctx = context.WithValue(ctx, "user", user)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// RateLimitMiddleware limits requests per route group. Requests are keyed on
+// the authenticated user when AuthTokenMiddleware ran before it, and on the
+// client IP resolved by middleware.RealIP otherwise.
+func (app *application) RateLimitMiddleware(group string, cfg ratelimit.Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !app.config.rateLimit.enabled {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := group + ":ip:" + clientIP(r)
+			if user := getUserFromCtx(r); user != nil {
+				key = group + ":user:" + strconv.FormatInt(user.ID, 10)
+			}
+
+			res, err := app.rateLimiter.Allow(r.Context(), key, cfg)
+			if err != nil {
+				// Fail open, an unavailable counter store should not take the API down
+				app.logger.Errorw("Rate limiter failed", "error", err, "key", key)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+
+			if !res.Allowed {
+				app.rateLimitExceededResponse(w, r, strconv.Itoa(ceilSeconds(res.RetryAfter)))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
 func (app *application) getUser(ctx context.Context, userID int64) (*store.User, error) {
 	user, err := app.store.UsersRepo.GetByID(ctx, userID)
 	if err != nil {
@@ -122,3 +191,54 @@ func (app *application) getUser(ctx context.Context, userID int64) (*store.User,
 
 	return user, nil
 }
+
+// RequirePermission rejects requests whose authenticated user lacks any of
+// the given permissions. It must run after AuthTokenMiddleware.
+func (app *application) RequirePermission(permissions ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := getUserFromCtx(r)
+
+			for _, permission := range permissions {
+				if !user.Role.HasPermission(permission) {
+					app.forbiddenResponse(w, r)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// checkPostOwnership only lets the post author through, unless the
+// authenticated user holds the given permission.
+func (app *application) checkPostOwnership(permission string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := getUserFromCtx(r)
+		post := getPostFromCtx(r)
+
+		if post.UserID != user.ID && !user.Role.HasPermission(permission) {
+			app.forbiddenResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
+
+// checkCommentOwnership only lets the comment author through, unless the
+// authenticated user holds the given permission.
+func (app *application) checkCommentOwnership(permission string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := getUserFromCtx(r)
+		comment := getCommentFromCtx(r)
+
+		if comment.UserID != user.ID && !user.Role.HasPermission(permission) {
+			app.forbiddenResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/moabdelazem/social/internal/mailer"
+	"github.com/moabdelazem/social/internal/store"
+)
+
+// newOutboxMessage builds an email for the outbox; the recipient is filled
+// in by the store from the user the email belongs to.
+func newOutboxMessage(subject, template string, data mailer.EmailData) (*store.OutboxMessage, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &store.OutboxMessage{
+		Subject:  subject,
+		Template: template,
+		Data:     payload,
+	}, nil
+}
+
+// requestLocale returns the client's preferred language from Accept-Language,
+// used to pick the localized variant of an email template.
+func requestLocale(r *http.Request) string {
+	lang, _, _ := strings.Cut(r.Header.Get("Accept-Language"), ",")
+	lang, _, _ = strings.Cut(lang, ";")
+	return strings.TrimSpace(lang)
+}
+
+func (app *application) listFailedEmailsHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse pagination query parameters
+	cq := store.PaginatedCursorQuery{}
+	cq, err := cq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Validate pagination parameters
+	if err := Validate.Struct(cq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	messages, next, err := app.store.OutboxRepo.ListFailed(ctx, cq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.paginatedResponse(w, r, http.StatusOK, messages, cq.Page(store.PageCursors{Next: next})); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
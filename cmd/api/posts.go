@@ -5,20 +5,40 @@ import (
 	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/moabdelazem/social/internal/store"
 )
 
+var (
+	errUnpublishPost   = errors.New("a published post cannot go back to draft or scheduled")
+	errPublishAtNeeded = errors.New("scheduled posts need a publish_at in the future")
+	errPublishAtUnused = errors.New("publish_at is only allowed on scheduled posts")
+)
+
 type CreatePostPayload struct {
-	Title   string   `json:"title" validate:"required,max=100"`
-	Content string   `json:"content" validate:"required,max=1000"`
-	Tags    []string `json:"tags"`
+	Title     string     `json:"title" validate:"required,max=100"`
+	Content   string     `json:"content" validate:"required,max=1000"`
+	Tags      []string   `json:"tags"`
+	Status    string     `json:"status" validate:"omitempty,oneof=draft scheduled published"`
+	PublishAt *time.Time `json:"publish_at"`
 }
 
 type UpdatePostPayload struct {
-	Title   *string `json:"title" validate:"omitempty,max=100"`
-	Content *string `json:"content" validate:"omitempty,max=100"`
+	Title     *string    `json:"title" validate:"omitempty,max=100"`
+	Content   *string    `json:"content" validate:"omitempty,max=100"`
+	Status    *string    `json:"status" validate:"omitempty,oneof=draft scheduled published"`
+	PublishAt *time.Time `json:"publish_at"`
+	Version   *int       `json:"version"` // optional, the version the client edited
+}
+
+// postWithReactions is a post along with its comments and the reactions it
+// received
+type postWithReactions struct {
+	*store.Post
+	Comments []commentView `json:"comments"`
+	*store.PostReactions
 }
 
 func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request) {
@@ -43,6 +63,12 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 		Content: payload.Content,
 		Tags:    payload.Tags,
 	}
+
+	if err := setPostStatus(post, payload.Status, payload.PublishAt); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
 	ctx := r.Context()
 	if err := app.store.PostsRepo.Create(ctx, post); err != nil {
 		app.internalServerError(w, r, err)
@@ -53,8 +79,14 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 		"post_id", post.ID,
 		"user_id", post.UserID,
 		"title", post.Title,
+		"status", post.Status,
 	)
 
+	if post.Status == store.PostStatusPublished {
+		app.fanOutPost(post)
+	}
+
+	w.Header().Set("ETag", versionETag(post.Version))
 	if err := app.jsonResponse(w, http.StatusCreated, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -64,15 +96,38 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 	post := getPostFromCtx(r)
 
+	if !app.canViewPost(w, r, post) {
+		return
+	}
+
 	ctx := r.Context()
-	comments, err := app.store.CommentRepo.GetByPostID(ctx, post.ID)
+	comments, err := app.store.CommentRepo.GetByPostID(ctx, post.ID, getUserFromCtx(r).ID)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
-	post.Comments = comments
 
-	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
+	reactions, err := app.store.ReactionRepo.GetByPostID(ctx, post.ID, getUserFromCtx(r).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	response := postWithReactions{Post: post, Comments: newCommentViews(comments), PostReactions: reactions}
+
+	// The body embeds comments and reactions, so the ETag covers them too
+	etag, err := contentETag(post.Version, response)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", etag)
+	if notModified(w, r, etag) {
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, response); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
@@ -81,15 +136,22 @@ func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 func (app *application) deletePostHandler(w http.ResponseWriter, r *http.Request) {
 	post := getPostFromCtx(r)
 
-	ctx := r.Context()
-	err := app.store.PostsRepo.Delete(ctx, post.ID)
+	if !app.checkIfMatch(w, r, post.Version) {
+		return
+	}
 
-	if err != nil {
-		app.internalServerError(w, r, err)
+	ctx := r.Context()
+	if err := app.store.PostsRepo.Delete(ctx, post.ID, post.Version); err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.versionConflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
 		return
 	}
 
-	app.logger.Infow("Post deleted",
+	app.logger.Infow("Post moved to trash",
 		"post_id", post.ID,
 		"user_id", post.UserID,
 	)
@@ -111,6 +173,15 @@ func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if !app.checkIfMatch(w, r, post.Version) {
+		return
+	}
+
+	if payload.Version != nil && *payload.Version != post.Version {
+		app.editConflictResponse(w, r, store.ErrorEditConflict)
+		return
+	}
+
 	// Only update fields that were provided
 	if payload.Title != nil {
 		post.Title = *payload.Title
@@ -120,9 +191,26 @@ func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request
 		post.Content = *payload.Content
 	}
 
+	wasPublished := post.Status == store.PostStatusPublished
+
+	var status string
+	if payload.Status != nil {
+		status = *payload.Status
+	}
+
+	if err := setPostStatus(post, status, payload.PublishAt); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
 	ctx := r.Context()
 	if err := app.store.PostsRepo.Update(ctx, post); err != nil {
-		app.internalServerError(w, r, err)
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.versionConflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
 		return
 	}
 
@@ -130,15 +218,130 @@ func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request
 		"post_id", post.ID,
 		"user_id", post.UserID,
 		"version", post.Version,
+		"status", post.Status,
 	)
 
+	if !wasPublished && post.Status == store.PostStatusPublished {
+		app.fanOutPost(post)
+	}
+
+	w.Header().Set("ETag", versionETag(post.Version))
 	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 }
 
+func (app *application) listDraftsHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse pagination query parameters
+	cq := store.PaginatedCursorQuery{}
+	cq, err := cq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Validate pagination parameters
+	if err := Validate.Struct(cq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	drafts, next, err := app.store.PostsRepo.GetDrafts(r.Context(), user.ID, cq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.paginatedResponse(w, r, http.StatusOK, drafts, cq.Page(store.PageCursors{Next: next})); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// setPostStatus moves post to status, scheduled at publishAt. An empty
+// status keeps the post's current one, or schedules the post when publishAt
+// is given; new posts are published by default.
+func setPostStatus(post *store.Post, status string, publishAt *time.Time) error {
+	if status == "" {
+		status = post.Status
+		if publishAt != nil {
+			status = store.PostStatusScheduled
+		}
+	}
+	if status == "" {
+		status = store.PostStatusPublished
+	}
+
+	if post.Status == store.PostStatusPublished && status != store.PostStatusPublished {
+		return errUnpublishPost
+	}
+
+	switch status {
+	case store.PostStatusScheduled:
+		// Edits of a scheduled post keep its date unless a new one is given
+		if publishAt == nil && post.Status == store.PostStatusScheduled {
+			publishAt = post.PublishAt
+		} else if publishAt == nil || !publishAt.After(time.Now()) {
+			return errPublishAtNeeded
+		}
+		post.PublishAt = publishAt
+	case store.PostStatusDraft:
+		if publishAt != nil {
+			return errPublishAtUnused
+		}
+		post.PublishAt = nil
+	case store.PostStatusPublished:
+		if publishAt != nil {
+			return errPublishAtUnused
+		}
+		// Only posts published by the scheduler remember their publish_at
+		if post.Status != store.PostStatusPublished {
+			post.PublishAt = nil
+		}
+	}
+
+	post.Status = status
+	return nil
+}
+
+// canViewPost reports whether the authenticated user may see post, which
+// private and blocking authors hide, as well as posts that are not published
+// yet. When it may not, it responds with 404.
+func (app *application) canViewPost(w http.ResponseWriter, r *http.Request, post *store.Post) bool {
+	viewer := getUserFromCtx(r)
+
+	if post.Status != store.PostStatusPublished && post.UserID != viewer.ID {
+		app.notFoundResponse(w, r, store.ErrorNotFound)
+		return false
+	}
+
+	visible, err := app.store.RelationRepo.CanViewPosts(r.Context(), viewer.ID, post.UserID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return false
+	}
+
+	if !visible {
+		app.notFoundResponse(w, r, store.ErrorNotFound)
+		return false
+	}
+
+	return true
+}
+
 func (app *application) postsContextMiddleware(next http.Handler) http.Handler {
+	return app.postContext(next, app.store.PostsRepo.GetByID)
+}
+
+// trashedPostsContextMiddleware loads a post that is in the trash
+func (app *application) trashedPostsContextMiddleware(next http.Handler) http.Handler {
+	return app.postContext(next, app.store.PostsRepo.GetTrashedByID)
+}
+
+// postContext puts the {postID} post loaded with fetch in the request context
+func (app *application) postContext(next http.Handler, fetch func(context.Context, int64) (*store.Post, error)) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		idParam := chi.URLParam(r, "postID")
 		post_id, err := strconv.ParseInt(idParam, 10, 64)
@@ -148,7 +351,7 @@ func (app *application) postsContextMiddleware(next http.Handler) http.Handler {
 		}
 
 		ctx := r.Context()
-		post, err := app.store.PostsRepo.GetByID(ctx, post_id)
+		post, err := fetch(ctx, post_id)
 
 		if err != nil {
 			switch {
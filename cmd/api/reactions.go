@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/moabdelazem/social/internal/store"
+)
+
+func (app *application) addReactionHandler(w http.ResponseWriter, r *http.Request) {
+	app.updateReaction(w, r, "Reaction added", app.store.ReactionRepo.Add)
+}
+
+func (app *application) removeReactionHandler(w http.ResponseWriter, r *http.Request) {
+	app.updateReaction(w, r, "Reaction removed", app.store.ReactionRepo.Remove)
+}
+
+// updateReaction applies update to the authenticated user's {kind} reaction
+// to the post in context
+func (app *application) updateReaction(w http.ResponseWriter, r *http.Request, msg string, update func(ctx context.Context, postID, userID int64, kind string) error) {
+	kind := chi.URLParam(r, "kind")
+	if !slices.Contains(store.ReactionKinds, kind) {
+		app.badRequestResponse(w, r, fmt.Errorf("unknown reaction %q", kind))
+		return
+	}
+
+	user := getUserFromCtx(r)
+	post := getPostFromCtx(r)
+
+	if !app.canViewPost(w, r, post) {
+		return
+	}
+
+	if err := update(r.Context(), post.ID, user.ID, kind); err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.logger.Infow(msg,
+		"post_id", post.ID,
+		"user_id", user.ID,
+		"kind", kind,
+	)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/moabdelazem/social/internal/diff"
+	"github.com/moabdelazem/social/internal/store"
+)
+
+// revisionDiff is the line by line difference between two versions of a post
+type revisionDiff struct {
+	From    int       `json:"from"`
+	To      int       `json:"to"`
+	Title   []diff.Op `json:"title"`
+	Content []diff.Op `json:"content"`
+}
+
+func (app *application) listRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse pagination query parameters
+	cq := store.PaginatedCursorQuery{}
+	cq, err := cq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Validate pagination parameters
+	if err := Validate.Struct(cq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	post := getPostFromCtx(r)
+
+	if !app.canViewPost(w, r, post) {
+		return
+	}
+
+	revisions, next, err := app.store.RevisionRepo.List(r.Context(), post.ID, cq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.paginatedResponse(w, r, http.StatusOK, revisions, cq.Page(store.PageCursors{Next: next})); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) getRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	if !app.canViewPost(w, r, post) {
+		return
+	}
+
+	version, err := strconv.Atoi(chi.URLParam(r, "version"))
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	rev, ok := app.revisionOrNotFound(w, r, post, version)
+	if !ok {
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, rev); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// diffRevisionsHandler compares the ?from and ?to versions of a post. to
+// defaults to the current version and from to the version before to.
+func (app *application) diffRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	if !app.canViewPost(w, r, post) {
+		return
+	}
+
+	qs := r.URL.Query()
+
+	to := post.Version
+	if v := qs.Get("to"); v != "" {
+		var err error
+		if to, err = strconv.Atoi(v); err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	}
+
+	from := to - 1
+	if v := qs.Get("from"); v != "" {
+		var err error
+		if from, err = strconv.Atoi(v); err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	}
+
+	fromRev, ok := app.revisionOrNotFound(w, r, post, from)
+	if !ok {
+		return
+	}
+
+	toRev, ok := app.revisionOrNotFound(w, r, post, to)
+	if !ok {
+		return
+	}
+
+	result := revisionDiff{
+		From:    fromRev.Version,
+		To:      toRev.Version,
+		Title:   diff.Lines(fromRev.Title, toRev.Title),
+		Content: diff.Lines(fromRev.Content, toRev.Content),
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, result); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// restoreRevisionHandler saves the title and content of an older revision as
+// a new version of the post
+func (app *application) restoreRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	if !app.checkIfMatch(w, r, post.Version) {
+		return
+	}
+
+	version, err := strconv.Atoi(chi.URLParam(r, "version"))
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	rev, ok := app.revisionOrNotFound(w, r, post, version)
+	if !ok {
+		return
+	}
+
+	post.Title = rev.Title
+	post.Content = rev.Content
+
+	ctx := r.Context()
+	if err := app.store.PostsRepo.Update(ctx, post); err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.versionConflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.logger.Infow("Post revision restored",
+		"post_id", post.ID,
+		"restored_version", rev.Version,
+		"version", post.Version,
+	)
+
+	w.Header().Set("ETag", versionETag(post.Version))
+	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// revisionOrNotFound loads the revision of post at version, responding with
+// 404 when it does not exist
+func (app *application) revisionOrNotFound(w http.ResponseWriter, r *http.Request, post *store.Post, version int) (*store.PostRevision, bool) {
+	rev, err := app.store.RevisionRepo.Get(r.Context(), post.ID, version)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return nil, false
+	}
+
+	return rev, true
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// schedulerBatchSize is the number of due posts published per query
+const schedulerBatchSize = 100
+
+// publishScheduledPosts publishes scheduled posts once their publish_at has
+// passed, checking every scheduler interval until ctx is done. Every replica
+// runs it; PostStore.PublishDue skips the rows another replica is claiming.
+func (app *application) publishScheduledPosts(ctx context.Context) {
+	ticker := time.NewTicker(app.config.scheduler.interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			posts, err := app.store.PostsRepo.PublishDue(ctx, schedulerBatchSize)
+			if err != nil {
+				if ctx.Err() == nil {
+					app.logger.Errorw("Failed to publish scheduled posts", "error", err)
+				}
+				break
+			}
+
+			for i := range posts {
+				post := &posts[i]
+
+				app.logger.Infow("Scheduled post published",
+					"post_id", post.ID,
+					"user_id", post.UserID,
+					"publish_at", post.PublishAt,
+				)
+
+				app.fanOutPost(post)
+			}
+
+			// Keep going while whole batches are due
+			if len(posts) < schedulerBatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
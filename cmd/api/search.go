@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/moabdelazem/social/internal/store"
+)
+
+func (app *application) searchHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse search query parameters
+	sq := store.SearchQuery{}
+	sq, err := sq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Validate search parameters
+	if err := Validate.Struct(sq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	viewer := getUserFromCtx(r)
+	ctx := r.Context()
+
+	var (
+		hits    any
+		hasMore bool
+	)
+
+	switch sq.Type {
+	case store.SearchTypePosts:
+		hits, hasMore, err = app.store.SearchRepo.SearchPosts(ctx, viewer.ID, sq)
+	case store.SearchTypeUsers:
+		hits, hasMore, err = app.store.SearchRepo.SearchUsers(ctx, viewer.ID, sq)
+	case store.SearchTypeTags:
+		hits, hasMore, err = app.store.SearchRepo.SearchTags(ctx, viewer.ID, sq)
+	}
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	results := store.NewSearchResults(sq, hits, hasMore)
+	setPaginationLinks(w, r, results.Pagination)
+
+	if err := app.jsonResponse(w, http.StatusOK, results); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/moabdelazem/social/internal/store"
+)
+
+// trashPurgeBatchSize is the number of expired posts purged per query
+const trashPurgeBatchSize = 500
+
+func (app *application) listTrashHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse pagination query parameters
+	cq := store.PaginatedCursorQuery{}
+	cq, err := cq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Validate pagination parameters
+	if err := Validate.Struct(cq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	posts, next, err := app.store.PostsRepo.GetTrash(r.Context(), user.ID, cq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.paginatedResponse(w, r, http.StatusOK, posts, cq.Page(store.PageCursors{Next: next})); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) restorePostHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	ctx := r.Context()
+	if err := app.store.PostsRepo.Restore(ctx, post); err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.logger.Infow("Post restored",
+		"post_id", post.ID,
+		"user_id", post.UserID,
+	)
+
+	w.Header().Set("ETag", versionETag(post.Version))
+	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// purgeTrash permanently deletes the posts that stayed in the trash longer
+// than the retention period, every purge interval until ctx is done
+func (app *application) purgeTrash(ctx context.Context) {
+	ticker := time.NewTicker(app.config.trash.purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		before := time.Now().Add(-app.config.trash.retention)
+
+		for {
+			purged, err := app.store.PostsRepo.PurgeTrash(ctx, before, trashPurgeBatchSize)
+			if err != nil {
+				if ctx.Err() == nil {
+					app.logger.Errorw("Failed to purge trash", "error", err)
+				}
+				break
+			}
+
+			if purged > 0 {
+				app.logger.Infow("Trash purged", "posts", purged)
+			}
+
+			// Keep going while whole batches expire
+			if purged < trashPurgeBatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -1,37 +1,188 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"net/http"
+	"strconv"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/moabdelazem/social/internal/store"
 )
 
+type UpdateProfilePayload struct {
+	DisplayName *string `json:"display_name" validate:"omitempty,max=50"`
+	Bio         *string `json:"bio" validate:"omitempty,max=160"`
+	AvatarURL   *string `json:"avatar_url" validate:"omitempty,http_url,max=2048"`
+	IsPrivate   *bool   `json:"is_private"`
+	Version     *int    `json:"version"`
+}
+
 func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
+	user := getTargetUserFromCtx(r)
+
+	app.writeProfile(w, r, user)
+}
+
+// writeProfile responds with the viewer's view of user along with its follow
+// counts. Users who blocked the viewer are reported as not found.
+func (app *application) writeProfile(w http.ResponseWriter, r *http.Request, user *store.User) {
+	rel, ok := app.relationshipOrNotFound(w, r, user)
+	if !ok {
+		return
+	}
+
+	counts, err := app.store.FollowerRepo.GetCounts(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, newUserView(getUserFromCtx(r), user, counts, rel)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// relationshipOrNotFound loads how the authenticated user relates to user,
+// which is nil for the user themselves. When user blocked the viewer it
+// responds with 404 and returns false.
+func (app *application) relationshipOrNotFound(w http.ResponseWriter, r *http.Request, user *store.User) (*store.Relationship, bool) {
+	viewer := getUserFromCtx(r)
+	if viewer.ID == user.ID {
+		return nil, true
+	}
+
+	rel, err := app.store.RelationRepo.Get(r.Context(), viewer.ID, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return nil, false
+	}
+
+	if rel.BlockedBy {
+		app.notFoundResponse(w, r, store.ErrorBlocked)
+		return nil, false
+	}
+
+	return rel, true
+}
+
+func (app *application) getUserByUsernameHandler(w http.ResponseWriter, r *http.Request) {
+	username := chi.URLParam(r, "username")
+
+	ctx := r.Context()
+	user, err := app.store.UsersRepo.GetByUsername(ctx, username)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.writeProfile(w, r, user)
+}
+
+func (app *application) getCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.writeProfile(w, r, getUserFromCtx(r))
+}
+
+func (app *application) updateProfileHandler(w http.ResponseWriter, r *http.Request) {
 	user := getUserFromCtx(r)
 
-	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
+	var payload UpdateProfilePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Reject stale edits when the client says which version it edited
+	if payload.Version != nil && *payload.Version != user.Version {
+		app.editConflictResponse(w, r, store.ErrorEditConflict)
+		return
+	}
+
+	// Only update fields that were provided
+	if payload.DisplayName != nil {
+		user.DisplayName = *payload.DisplayName
+	}
+
+	if payload.Bio != nil {
+		user.Bio = *payload.Bio
+	}
+
+	if payload.AvatarURL != nil {
+		user.AvatarURL = *payload.AvatarURL
+	}
+
+	if payload.IsPrivate != nil {
+		user.IsPrivate = *payload.IsPrivate
+	}
+
+	ctx := r.Context()
+	if err := app.store.UsersRepo.UpdateProfile(ctx, user); err != nil {
+		switch {
+		case errors.Is(err, store.ErrorEditConflict):
+			app.editConflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.logger.Infow("Profile updated",
+		"user_id", user.ID,
+		"version", user.Version,
+	)
+
+	if err := app.jsonResponse(w, http.StatusOK, newPrivateUser(user)); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
 
 func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request) {
-	userToFollow := getUserFromCtx(r)
+	userToFollow := getTargetUserFromCtx(r)
 
 	// Get the authenticated user from JWT token (the follower)
-	authenticatedUser := r.Context().Value("user").(*store.User)
+	authenticatedUser := getUserFromCtx(r)
 
 	ctx := r.Context()
-	if err := app.store.FollowerRepo.Follow(ctx, authenticatedUser.ID, userToFollow.ID); err != nil {
+	requested, err := app.store.FollowerRepo.Follow(ctx, authenticatedUser.ID, userToFollow.ID)
+	if err != nil {
 		switch {
 		case errors.Is(err, store.ErrorConflict):
 			app.conflictResponse(w, r, err)
+		case errors.Is(err, store.ErrorBlocked):
+			app.forbiddenResponse(w, r)
+		case errors.Is(err, store.ErrorNotFound):
+			app.notFoundResponse(w, r, err)
 		default:
 			app.internalServerError(w, r, err)
 		}
 		return
 	}
 
+	// Private accounts approve their followers
+	if requested {
+		app.logger.Infow("Follow requested",
+			"follower_id", authenticatedUser.ID,
+			"user_id", userToFollow.ID,
+		)
+
+		if err := app.jsonResponse(w, http.StatusAccepted, map[string]string{
+			"message": "follow request sent",
+		}); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
 	app.logger.Infow("User followed",
 		"follower_id", authenticatedUser.ID,
 		"user_id", userToFollow.ID,
@@ -41,10 +192,10 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 }
 
 func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Request) {
-	userToUnfollow := getUserFromCtx(r)
+	userToUnfollow := getTargetUserFromCtx(r)
 
 	// Get the authenticated user from JWT token (the follower)
-	authenticatedUser := r.Context().Value("user").(*store.User)
+	authenticatedUser := getUserFromCtx(r)
 
 	ctx := r.Context()
 	if err := app.store.FollowerRepo.Unfollow(ctx, authenticatedUser.ID, userToUnfollow.ID); err != nil {
@@ -66,16 +217,214 @@ func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Reque
 }
 
 func (app *application) getUserPostsHandler(w http.ResponseWriter, r *http.Request) {
-	user := getUserFromCtx(r)
+	app.listUserPosts(w, r, getUserFromCtx(r))
+}
+
+func (app *application) getTargetUserPostsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getTargetUserFromCtx(r)
+
+	if _, ok := app.relationshipOrNotFound(w, r, user); !ok {
+		return
+	}
+
+	// Private accounts only show their posts to approved followers
+	visible, err := app.store.RelationRepo.CanViewPosts(r.Context(), getUserFromCtx(r).ID, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if !visible {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	app.listUserPosts(w, r, user)
+}
+
+// listUserPosts responds with a page of the posts written by user
+func (app *application) listUserPosts(w http.ResponseWriter, r *http.Request, user *store.User) {
+	// Parse pagination query parameters
+	fq := store.PaginatedFeedQuery{}
+	fq, err := fq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Validate pagination parameters
+	if err := Validate.Struct(fq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if fq.Sort == "top" {
+		app.badRequestResponse(w, r, errors.New("sort=top is only supported by the feed"))
+		return
+	}
 
 	ctx := r.Context()
-	posts, err := app.store.PostsRepo.GetByUserID(ctx, user.ID)
+	posts, cursors, err := app.store.PostsRepo.GetByUserID(ctx, user.ID, fq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.paginatedResponse(w, r, http.StatusOK, posts, fq.Page(cursors)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) listFollowersHandler(w http.ResponseWriter, r *http.Request) {
+	app.listFollows(w, r, app.store.FollowerRepo.ListFollowers)
+}
+
+func (app *application) listFollowingHandler(w http.ResponseWriter, r *http.Request) {
+	app.listFollows(w, r, app.store.FollowerRepo.ListFollowing)
+}
+
+// followsLister loads a page of a user's followers or following list
+type followsLister func(ctx context.Context, userID, viewerID int64, cq store.PaginatedCursorQuery) ([]store.FollowEntry, string, error)
+
+// listFollows responds with a page of the target user's follows loaded by list
+func (app *application) listFollows(w http.ResponseWriter, r *http.Request, list followsLister) {
+	// Parse pagination query parameters
+	cq := store.PaginatedCursorQuery{}
+	cq, err := cq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Validate pagination parameters
+	if err := Validate.Struct(cq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getTargetUserFromCtx(r)
+	viewer := getUserFromCtx(r)
+
+	if _, ok := app.relationshipOrNotFound(w, r, user); !ok {
+		return
+	}
+
+	entries, next, err := list(r.Context(), user.ID, viewer.ID, cq)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	if err := app.jsonResponse(w, http.StatusOK, posts); err != nil {
+	if err := app.paginatedResponse(w, r, http.StatusOK, newFollowEntries(entries), cq.Page(store.PageCursors{Next: next})); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
+
+func (app *application) blockUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.updateRelationship(w, r, "User blocked", app.store.RelationRepo.Block)
+}
+
+func (app *application) unblockUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.updateRelationship(w, r, "User unblocked", app.store.RelationRepo.Unblock)
+}
+
+func (app *application) muteUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.updateRelationship(w, r, "User muted", app.store.RelationRepo.Mute)
+}
+
+func (app *application) unmuteUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.updateRelationship(w, r, "User unmuted", app.store.RelationRepo.Unmute)
+}
+
+// updateRelationship applies a block or mute change from the authenticated
+// user to the target user
+func (app *application) updateRelationship(w http.ResponseWriter, r *http.Request, msg string, update func(ctx context.Context, userID, targetID int64) error) {
+	user := getUserFromCtx(r)
+	target := getTargetUserFromCtx(r)
+
+	if user.ID == target.ID {
+		app.badRequestResponse(w, r, errors.New("you cannot block or mute yourself"))
+		return
+	}
+
+	if err := update(r.Context(), user.ID, target.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.logger.Infow(msg,
+		"user_id", user.ID,
+		"target_id", target.ID,
+	)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) listFollowRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse pagination query parameters
+	cq := store.PaginatedCursorQuery{}
+	cq, err := cq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Validate pagination parameters
+	if err := Validate.Struct(cq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	requests, next, err := app.store.FollowerRepo.ListRequests(r.Context(), user.ID, cq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.paginatedResponse(w, r, http.StatusOK, newFollowEntries(requests), cq.Page(store.PageCursors{Next: next})); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) approveFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	app.answerFollowRequest(w, r, "Follow request approved", app.store.FollowerRepo.ApproveRequest)
+}
+
+func (app *application) rejectFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	app.answerFollowRequest(w, r, "Follow request rejected", app.store.FollowerRepo.RejectRequest)
+}
+
+// answerFollowRequest approves or rejects the request the {userID} user sent
+// to the authenticated user
+func (app *application) answerFollowRequest(w http.ResponseWriter, r *http.Request, msg string, answer func(ctx context.Context, userID, requesterID int64) error) {
+	requesterID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	if err := answer(r.Context(), user.ID, requesterID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.logger.Infow(msg,
+		"user_id", user.ID,
+		"requester_id", requesterID,
+	)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    token TEXT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    family_id UUID NOT NULL,
+    expiry TIMESTAMP(0) WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP(0) WITH TIME ZONE,
+    replaced_by TEXT,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CONSTRAINT fk_refresh_tokens_user_id FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_refresh_tokens_user_id ON refresh_tokens (user_id);
+
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens (family_id);
+
+CREATE INDEX idx_refresh_tokens_expiry ON refresh_tokens (expiry);
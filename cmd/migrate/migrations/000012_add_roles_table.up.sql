@@ -0,0 +1,38 @@
+CREATE TABLE IF NOT EXISTS roles (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    level INT NOT NULL DEFAULT 0,
+    description TEXT
+);
+
+INSERT INTO
+    roles (name, level, description)
+VALUES (
+        'user',
+        1,
+        'A user can create posts and comments'
+    ),
+    (
+        'moderator',
+        2,
+        'A moderator can update other users posts'
+    ),
+    (
+        'admin',
+        3,
+        'An admin can update and delete other users posts'
+    );
+
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS role_id BIGINT REFERENCES roles (id) DEFAULT 1;
+
+UPDATE users
+SET
+    role_id = (
+        SELECT id
+        FROM roles
+        WHERE
+            name = 'user'
+    );
+
+ALTER TABLE users ALTER COLUMN role_id SET NOT NULL;
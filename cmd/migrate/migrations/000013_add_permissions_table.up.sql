@@ -0,0 +1,54 @@
+CREATE TABLE IF NOT EXISTS permissions (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    description TEXT
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role_id BIGINT NOT NULL,
+    permission_id BIGINT NOT NULL,
+
+    PRIMARY KEY (role_id, permission_id),
+
+    FOREIGN KEY (role_id) REFERENCES roles (id) ON DELETE CASCADE,
+    FOREIGN KEY (permission_id) REFERENCES permissions (id) ON DELETE CASCADE
+);
+
+INSERT INTO
+    permissions (name, description)
+VALUES (
+        'posts:create',
+        'Create new posts'
+    ),
+    (
+        'posts:update:any',
+        'Update posts written by other users'
+    ),
+    (
+        'posts:delete:any',
+        'Delete posts written by other users'
+    );
+
+INSERT INTO
+    role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+    JOIN permissions p ON p.name IN ('posts:create')
+WHERE
+    r.name IN ('user', 'moderator', 'admin');
+
+INSERT INTO
+    role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+    JOIN permissions p ON p.name IN ('posts:update:any')
+WHERE
+    r.name IN ('moderator', 'admin');
+
+INSERT INTO
+    role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+    JOIN permissions p ON p.name IN ('posts:delete:any')
+WHERE
+    r.name = 'admin';
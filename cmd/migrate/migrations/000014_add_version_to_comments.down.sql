@@ -0,0 +1,12 @@
+DELETE FROM permissions
+WHERE
+    name IN (
+        'comments:update:any',
+        'comments:delete:any'
+    );
+
+DROP INDEX IF EXISTS idx_comments_post_id_created_at_id;
+
+ALTER TABLE comments DROP COLUMN IF EXISTS updated_at;
+
+ALTER TABLE comments DROP COLUMN IF EXISTS version;
@@ -0,0 +1,29 @@
+ALTER TABLE comments
+ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 0;
+
+ALTER TABLE comments
+ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW();
+
+-- Keyset pagination over a post's comments
+CREATE INDEX IF NOT EXISTS idx_comments_post_id_created_at_id ON comments (post_id, created_at, id);
+
+INSERT INTO
+    permissions (name, description)
+VALUES (
+        'comments:update:any',
+        'Update comments written by other users'
+    ),
+    (
+        'comments:delete:any',
+        'Delete comments written by other users'
+    );
+
+INSERT INTO
+    role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+    JOIN permissions p ON p.name IN (
+        'comments:update:any', 'comments:delete:any'
+    )
+WHERE
+    r.name IN ('moderator', 'admin');
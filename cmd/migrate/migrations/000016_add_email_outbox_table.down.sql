@@ -0,0 +1,3 @@
+DELETE FROM permissions WHERE name = 'outbox:read';
+
+DROP TABLE IF EXISTS email_outbox;
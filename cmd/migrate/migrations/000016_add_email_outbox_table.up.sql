@@ -0,0 +1,45 @@
+CREATE TABLE IF NOT EXISTS email_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    recipient citext NOT NULL,
+    subject TEXT NOT NULL,
+    template VARCHAR(100) NOT NULL,
+    data JSONB NOT NULL DEFAULT '{}',
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    max_attempts INT NOT NULL DEFAULT 8,
+    next_attempt_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_error TEXT,
+    sent_at TIMESTAMP(0) WITH TIME ZONE,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CONSTRAINT check_email_outbox_status CHECK (
+        status IN (
+            'pending',
+            'sending',
+            'sent',
+            'failed'
+        )
+    )
+);
+
+-- Messages waiting for delivery (or whose delivery lease expired)
+CREATE INDEX idx_email_outbox_due ON email_outbox (next_attempt_at)
+WHERE
+    status IN ('pending', 'sending');
+
+CREATE INDEX idx_email_outbox_status ON email_outbox (status, created_at DESC, id DESC);
+
+INSERT INTO
+    permissions (name, description)
+VALUES (
+        'outbox:read',
+        'List undeliverable outbox emails'
+    );
+
+INSERT INTO
+    role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+    JOIN permissions p ON p.name = 'outbox:read'
+WHERE
+    r.name = 'admin';
@@ -0,0 +1,26 @@
+-- Materialized home timelines, filled when posts are fanned out on write.
+-- Rows go away with the post or either user through the cascading keys.
+CREATE TABLE IF NOT EXISTS timelines (
+    user_id BIGINT NOT NULL,
+    post_id BIGINT NOT NULL,
+    author_id BIGINT NOT NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (user_id, post_id),
+    CONSTRAINT fk_timelines_user_id FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT fk_timelines_post_id FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE,
+    CONSTRAINT fk_timelines_author_id FOREIGN KEY (author_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+-- Cleaning up a timeline on unfollow
+CREATE INDEX IF NOT EXISTS idx_timelines_user_id_author_id ON timelines (user_id, author_id);
+
+CREATE INDEX IF NOT EXISTS idx_timelines_post_id ON timelines (post_id);
+
+-- Posts that were not fanned out (authors with too many followers, or not
+-- fanned out yet) are merged into timelines at read time.
+ALTER TABLE posts
+ADD COLUMN IF NOT EXISTS fanned_out BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_posts_not_fanned_out ON posts (user_id, created_at DESC, id DESC)
+WHERE
+    NOT fanned_out;
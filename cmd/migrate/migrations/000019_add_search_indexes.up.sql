@@ -0,0 +1,16 @@
+-- Full-text search over posts, titles rank above content
+ALTER TABLE posts
+ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+    setweight(
+        to_tsvector('english', coalesce(title, '')),
+        'A'
+    ) || setweight(
+        to_tsvector('english', coalesce(content, '')),
+        'B'
+    )
+) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_posts_search_vector ON posts USING GIN (search_vector);
+
+-- Fuzzy username search
+CREATE INDEX IF NOT EXISTS idx_users_username_trgm ON users USING GIN (username gin_trgm_ops);
@@ -0,0 +1,9 @@
+ALTER TABLE users DROP COLUMN IF EXISTS updated_at;
+
+ALTER TABLE users DROP COLUMN IF EXISTS version;
+
+ALTER TABLE users DROP COLUMN IF EXISTS avatar_url;
+
+ALTER TABLE users DROP COLUMN IF EXISTS bio;
+
+ALTER TABLE users DROP COLUMN IF EXISTS display_name;
@@ -0,0 +1,12 @@
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS display_name VARCHAR(50) NOT NULL DEFAULT '';
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS bio TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS avatar_url TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 0;
+
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW();
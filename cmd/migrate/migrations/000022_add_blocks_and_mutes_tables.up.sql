@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS blocks (
+    blocker_id BIGINT NOT NULL,
+    blocked_id BIGINT NOT NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (blocker_id, blocked_id),
+    CONSTRAINT fk_blocks_blocker_id FOREIGN KEY (blocker_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT fk_blocks_blocked_id FOREIGN KEY (blocked_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT check_no_self_block CHECK (blocker_id != blocked_id)
+);
+
+-- Looking up who blocked a user
+CREATE INDEX IF NOT EXISTS idx_blocks_blocked_id ON blocks (blocked_id);
+
+CREATE TABLE IF NOT EXISTS mutes (
+    muter_id BIGINT NOT NULL,
+    muted_id BIGINT NOT NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (muter_id, muted_id),
+    CONSTRAINT fk_mutes_muter_id FOREIGN KEY (muter_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT fk_mutes_muted_id FOREIGN KEY (muted_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT check_no_self_mute CHECK (muter_id != muted_id)
+);
@@ -0,0 +1,21 @@
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS is_private BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Pending requests to follow private accounts
+CREATE TABLE IF NOT EXISTS follow_requests (
+    user_id BIGINT NOT NULL,
+    requester_id BIGINT NOT NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, requester_id),
+    CONSTRAINT fk_follow_requests_user_id FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT fk_follow_requests_requester_id FOREIGN KEY (requester_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT check_no_self_follow_request CHECK (user_id != requester_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_follow_requests_user_id_created_at ON follow_requests (
+    user_id,
+    created_at DESC,
+    requester_id DESC
+);
+
+CREATE INDEX IF NOT EXISTS idx_follow_requests_requester_id ON follow_requests (requester_id);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS post_reactions (
+    post_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    kind VARCHAR(16) NOT NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (post_id, user_id, kind),
+    CONSTRAINT fk_post_reactions_post_id FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE,
+    CONSTRAINT fk_post_reactions_user_id FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT check_post_reactions_kind CHECK (kind IN ('like', 'love', 'laugh', 'wow', 'sad', 'angry'))
+);
+
+-- Author affinity counts the reactions a user left recently
+CREATE INDEX IF NOT EXISTS idx_post_reactions_user_id_created_at ON post_reactions (user_id, created_at);
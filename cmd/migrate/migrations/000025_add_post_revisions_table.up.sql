@@ -0,0 +1,17 @@
+-- One row per version of a post, including the current one
+CREATE TABLE IF NOT EXISTS post_revisions (
+    post_id BIGINT NOT NULL,
+    version INT NOT NULL,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    tags VARCHAR(100) [],
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (post_id, version),
+    CONSTRAINT fk_post_revisions_post_id FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE
+);
+
+-- Existing posts start their history at their current version
+INSERT INTO post_revisions (post_id, version, title, content, tags, created_at)
+SELECT id, version, title, content, tags, updated_at
+FROM posts
+ON CONFLICT DO NOTHING;
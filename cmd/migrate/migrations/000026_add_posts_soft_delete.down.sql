@@ -0,0 +1,8 @@
+ALTER TABLE comments DROP CONSTRAINT IF EXISTS fk_comments_post_id;
+
+DROP INDEX IF EXISTS idx_posts_deleted_at;
+
+-- Posts in the trash would reappear without the column
+DELETE FROM posts WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS deleted_at;
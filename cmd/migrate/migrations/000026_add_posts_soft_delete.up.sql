@@ -0,0 +1,14 @@
+ALTER TABLE posts
+ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP(0) WITH TIME ZONE;
+
+-- Listing a user's trash and purging expired posts
+CREATE INDEX IF NOT EXISTS idx_posts_deleted_at ON posts (deleted_at, id)
+WHERE deleted_at IS NOT NULL;
+
+-- Comments of hard deleted posts were left behind, drop them and let
+-- purged posts take their comments with them from now on
+DELETE FROM comments c
+WHERE NOT EXISTS (SELECT 1 FROM posts p WHERE p.id = c.post_id);
+
+ALTER TABLE comments
+ADD CONSTRAINT fk_comments_post_id FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE;
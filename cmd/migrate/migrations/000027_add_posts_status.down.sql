@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_posts_user_id_unpublished;
+DROP INDEX IF EXISTS idx_posts_scheduled_publish_at;
+
+-- Unpublished posts would go public without the column
+DELETE FROM posts WHERE status <> 'published';
+
+ALTER TABLE posts
+DROP CONSTRAINT IF EXISTS check_posts_scheduled_publish_at,
+DROP CONSTRAINT IF EXISTS check_posts_status,
+DROP COLUMN IF EXISTS publish_at,
+DROP COLUMN IF EXISTS status;
@@ -0,0 +1,15 @@
+ALTER TABLE posts
+ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'published',
+ADD COLUMN IF NOT EXISTS publish_at TIMESTAMP(0) WITH TIME ZONE;
+
+ALTER TABLE posts
+ADD CONSTRAINT check_posts_status CHECK (status IN ('draft', 'scheduled', 'published')),
+ADD CONSTRAINT check_posts_scheduled_publish_at CHECK (status <> 'scheduled' OR publish_at IS NOT NULL);
+
+-- The scheduler looks for due scheduled posts
+CREATE INDEX IF NOT EXISTS idx_posts_scheduled_publish_at ON posts (publish_at)
+WHERE status = 'scheduled';
+
+-- Listing a user's drafts
+CREATE INDEX IF NOT EXISTS idx_posts_user_id_unpublished ON posts (user_id, created_at DESC, id DESC)
+WHERE status <> 'published';
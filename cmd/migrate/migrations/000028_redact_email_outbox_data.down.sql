@@ -0,0 +1 @@
+-- Redacted data cannot be restored
@@ -0,0 +1,3 @@
+-- Delivered and dead-lettered emails kept their template data, including
+-- plaintext activation and password reset links
+UPDATE email_outbox SET data = '{}' WHERE status IN ('sent', 'failed');
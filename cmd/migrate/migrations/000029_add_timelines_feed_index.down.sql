@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_timelines_user_id_created_at;
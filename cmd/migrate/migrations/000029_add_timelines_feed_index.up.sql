@@ -0,0 +1,6 @@
+-- Reading a home timeline page by page, newest first
+CREATE INDEX IF NOT EXISTS idx_timelines_user_id_created_at ON timelines (
+    user_id,
+    created_at DESC,
+    post_id DESC
+);
@@ -0,0 +1,3 @@
+-- Bumped on password resets; access tokens carrying an older value are rejected
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS token_version INT NOT NULL DEFAULT 0;
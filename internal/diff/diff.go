@@ -0,0 +1,78 @@
+package diff
+
+import "strings"
+
+// Op kinds of a diff
+const (
+	Equal  = "equal"
+	Insert = "insert"
+	Delete = "delete"
+)
+
+// Op is a run of consecutive lines that are kept, inserted or deleted
+type Op struct {
+	Kind  string   `json:"op"`
+	Lines []string `json:"lines"`
+}
+
+// Lines returns the line by line edit script turning a into b, computed from
+// their longest common subsequence. Deletions come before insertions within
+// a change. Texts are short (posts), so the quadratic table is fine.
+func Lines(a, b string) []Op {
+	x, y := splitLines(a), splitLines(b)
+
+	// lcs[i][j] is the length of the LCS of x[i:] and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]Op, 0)
+	add := func(kind, line string) {
+		if n := len(ops); n > 0 && ops[n-1].Kind == kind {
+			ops[n-1].Lines = append(ops[n-1].Lines, line)
+			return
+		}
+		ops = append(ops, Op{Kind: kind, Lines: []string{line}})
+	}
+
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			add(Equal, x[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			add(Delete, x[i])
+			i++
+		default:
+			add(Insert, y[j])
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		add(Delete, x[i])
+	}
+	for ; j < len(y); j++ {
+		add(Insert, y[j])
+	}
+
+	return ops
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+}
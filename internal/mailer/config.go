@@ -0,0 +1,64 @@
+package mailer
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// Backends selectable through Config
+const (
+	BackendSMTP   = "smtp"
+	BackendLog    = "log"
+	BackendFile   = "file"
+	BackendMemory = "memory"
+)
+
+// Config selects and configures the email backend
+type Config struct {
+	Backend        string // one of the Backend* constants
+	SandboxBackend string // backend receiving sandboxed emails when Backend is smtp
+	SMTP           SMTPConfig
+	FileDir        string // directory used by the file backend
+}
+
+// New parses the email templates and creates the email client selected by config
+func New(config Config, logger *zap.SugaredLogger) (Client, error) {
+	templates, err := LoadTemplates()
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Backend != BackendSMTP {
+		return newBackend(config.Backend, config, templates, logger)
+	}
+
+	client := NewSMTPClient(config.SMTP, templates)
+
+	if config.SandboxBackend != "" {
+		if config.SandboxBackend == BackendSMTP {
+			return nil, fmt.Errorf("sandbox mailer backend cannot be %q", BackendSMTP)
+		}
+
+		sandbox, err := newBackend(config.SandboxBackend, config, templates, logger)
+		if err != nil {
+			return nil, err
+		}
+		client.sandbox = sandbox
+	}
+
+	return client, nil
+}
+
+func newBackend(backend string, config Config, templates *Templates, logger *zap.SugaredLogger) (Client, error) {
+	switch backend {
+	case BackendLog:
+		return NewLogMailer(logger, templates), nil
+	case BackendFile:
+		return NewFileMailer(config.FileDir, config.SMTP.From, templates)
+	case BackendMemory:
+		return NewMemoryMailer(templates), nil
+	default:
+		return nil, fmt.Errorf("unknown mailer backend %q", backend)
+	}
+}
@@ -0,0 +1,52 @@
+package mailer
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileMailer writes every email as an .eml file into a directory, which
+// can be opened with any mail client.
+type FileMailer struct {
+	dir       string
+	from      string
+	templates *Templates
+}
+
+// NewFileMailer creates a new email client writing into dir, creating it if needed
+func NewFileMailer(dir, from string, templates *Templates) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create mail directory: %w", err)
+	}
+
+	return &FileMailer{
+		dir:       dir,
+		from:      from,
+		templates: templates,
+	}, nil
+}
+
+// Send writes the email to a new .eml file
+func (m *FileMailer) Send(to, subject, templateName string, data any, isSandbox bool) (int, error) {
+	message, err := buildMessage(m.templates, m.from, to, subject, templateName, data)
+	if err != nil {
+		return 0, err
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return 0, err
+	}
+
+	name := fmt.Sprintf("%s-%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), templateName, hex.EncodeToString(suffix))
+
+	if err := os.WriteFile(filepath.Join(m.dir, name), message, 0o644); err != nil {
+		return 0, fmt.Errorf("failed to write email: %w", err)
+	}
+
+	return 200, nil
+}
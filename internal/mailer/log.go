@@ -0,0 +1,35 @@
+package mailer
+
+import "go.uber.org/zap"
+
+// LogMailer renders emails and writes their plain text part to the log instead of sending them
+type LogMailer struct {
+	logger    *zap.SugaredLogger
+	templates *Templates
+}
+
+// NewLogMailer creates a new log-only email client
+func NewLogMailer(logger *zap.SugaredLogger, templates *Templates) *LogMailer {
+	return &LogMailer{
+		logger:    logger,
+		templates: templates,
+	}
+}
+
+// Send logs the rendered email
+func (m *LogMailer) Send(to, subject, templateName string, data any, isSandbox bool) (int, error) {
+	_, text, err := m.templates.Render(templateName, data)
+	if err != nil {
+		return 0, err
+	}
+
+	m.logger.Infow("Email (not sent)",
+		"to", to,
+		"subject", subject,
+		"template", templateName,
+		"sandbox", isSandbox,
+		"body", text,
+	)
+
+	return 200, nil
+}
@@ -1,13 +1,14 @@
 package mailer
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
-	"html/template"
 	"net/smtp"
 	"time"
 )
 
+var ErrNoSandbox = errors.New("sandbox delivery requested but no sandbox mailer is configured")
+
 // Client represents an email client
 type Client interface {
 	Send(to, subject, body string, data any, isSandbox bool) (int, error)
@@ -17,8 +18,15 @@ type Client interface {
 type EmailData struct {
 	Username      string
 	ActivationURL string
+	ResetURL      string
 	ExpiryTime    time.Time
 	AppName       string
+	Locale        string
+}
+
+// EmailLocale implements Localized
+func (d EmailData) EmailLocale() string {
+	return d.Locale
 }
 
 // SMTPConfig holds SMTP server configuration
@@ -32,38 +40,40 @@ type SMTPConfig struct {
 
 // SMTPClient implements email sending via SMTP (Gmail, etc.)
 type SMTPClient struct {
-	config SMTPConfig
+	config    SMTPConfig
+	templates *Templates
+	sandbox   Client
 }
 
 // NewSMTPClient creates a new SMTP email client
-func NewSMTPClient(config SMTPConfig) *SMTPClient {
+func NewSMTPClient(config SMTPConfig, templates *Templates) *SMTPClient {
 	return &SMTPClient{
-		config: config,
+		config:    config,
+		templates: templates,
 	}
 }
 
-// Send sends an email using SMTP
+// Send sends an email using SMTP. Sandboxed emails are handed to the
+// sandbox client instead and never reach the SMTP server.
 func (c *SMTPClient) Send(to, subject, templateName string, data any, isSandbox bool) (int, error) {
-	// Parse and execute template
-	tmpl, err := template.ParseFS(templates, fmt.Sprintf("templates/%s.html", templateName))
-	if err != nil {
-		return 0, fmt.Errorf("failed to parse template: %w", err)
+	if isSandbox {
+		if c.sandbox == nil {
+			return 0, ErrNoSandbox
+		}
+		return c.sandbox.Send(to, subject, templateName, data, isSandbox)
 	}
 
-	var body bytes.Buffer
-	if err := tmpl.Execute(&body, data); err != nil {
-		return 0, fmt.Errorf("failed to execute template: %w", err)
+	message, err := buildMessage(c.templates, c.config.From, to, subject, templateName, data)
+	if err != nil {
+		return 0, err
 	}
 
-	// Build email message
-	message := c.buildMessage(to, subject, body.String())
-
 	// Setup authentication
 	auth := smtp.PlainAuth("", c.config.Username, c.config.Password, c.config.Host)
 
 	// Send email
 	addr := fmt.Sprintf("%s:%d", c.config.Host, c.config.Port)
-	err = smtp.SendMail(addr, auth, c.config.From, []string{to}, []byte(message))
+	err = smtp.SendMail(addr, auth, c.config.From, []string{to}, message)
 	if err != nil {
 		return 0, fmt.Errorf("failed to send email: %w", err)
 	}
@@ -71,14 +81,17 @@ func (c *SMTPClient) Send(to, subject, templateName string, data any, isSandbox
 	return 200, nil
 }
 
-// buildMessage constructs the email message with headers
-func (c *SMTPClient) buildMessage(to, subject, body string) string {
-	message := fmt.Sprintf("From: %s\r\n", c.config.From)
-	message += fmt.Sprintf("To: %s\r\n", to)
-	message += fmt.Sprintf("Subject: %s\r\n", subject)
-	message += "MIME-Version: 1.0\r\n"
-	message += "Content-Type: text/html; charset=\"UTF-8\"\r\n"
-	message += "\r\n"
-	message += body
-	return message
+// buildMessage renders the template and encodes the complete email
+func buildMessage(templates *Templates, from, to, subject, templateName string, data any) ([]byte, error) {
+	html, text, err := templates.Render(templateName, data)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := NewMessage(from, to, subject, text, html)
+	if err != nil {
+		return nil, err
+	}
+
+	return msg.Bytes()
 }
@@ -0,0 +1,81 @@
+package mailer
+
+import "sync"
+
+// SentEmail is an email captured by MemoryMailer
+type SentEmail struct {
+	To       string
+	Subject  string
+	Template string
+	Data     any
+	HTML     string
+	Text     string
+	Sandbox  bool
+}
+
+// MemoryMailer keeps sent emails in memory so tests can inspect them
+type MemoryMailer struct {
+	mu        sync.Mutex
+	emails    []SentEmail
+	templates *Templates
+}
+
+// NewMemoryMailer creates a new in-memory capture email client
+func NewMemoryMailer(templates *Templates) *MemoryMailer {
+	return &MemoryMailer{
+		templates: templates,
+	}
+}
+
+// Send renders the email and records it
+func (m *MemoryMailer) Send(to, subject, templateName string, data any, isSandbox bool) (int, error) {
+	html, text, err := m.templates.Render(templateName, data)
+	if err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.emails = append(m.emails, SentEmail{
+		To:       to,
+		Subject:  subject,
+		Template: templateName,
+		Data:     data,
+		HTML:     html,
+		Text:     text,
+		Sandbox:  isSandbox,
+	})
+
+	return 200, nil
+}
+
+// Emails returns a copy of every email captured so far
+func (m *MemoryMailer) Emails() []SentEmail {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]SentEmail(nil), m.emails...)
+}
+
+// EmailsTo returns the captured emails addressed to the given recipient
+func (m *MemoryMailer) EmailsTo(to string) []SentEmail {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var emails []SentEmail
+	for _, e := range m.emails {
+		if e.To == to {
+			emails = append(emails, e)
+		}
+	}
+	return emails
+}
+
+// Reset discards every captured email
+func (m *MemoryMailer) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.emails = nil
+}
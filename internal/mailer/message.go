@@ -0,0 +1,135 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Message is a multipart/alternative email with a plain text and an HTML part
+type Message struct {
+	From      string
+	To        string
+	Subject   string
+	Text      string
+	HTML      string
+	Date      time.Time
+	MessageID string
+}
+
+// NewMessage creates a message dated now with a unique Message-ID
+func NewMessage(from, to, subject, text, html string) (*Message, error) {
+	id, err := newMessageID(from)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		From:      from,
+		To:        to,
+		Subject:   subject,
+		Text:      text,
+		HTML:      html,
+		Date:      time.Now(),
+		MessageID: id,
+	}, nil
+}
+
+// Bytes encodes the message in RFC 5322 format. Header values are RFC 2047
+// encoded and both bodies are quoted-printable.
+func (m *Message) Bytes() ([]byte, error) {
+	from, err := formatAddress(m.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid from address: %w", err)
+	}
+
+	to, err := formatAddress(m.To)
+	if err != nil {
+		return nil, fmt.Errorf("invalid to address: %w", err)
+	}
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	headers := []struct{ key, value string }{
+		{"From", from},
+		{"To", to},
+		{"Subject", mime.QEncoding.Encode("UTF-8", m.Subject)},
+		{"Date", m.Date.Format(time.RFC1123Z)},
+		{"Message-ID", m.MessageID},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": mw.Boundary()})},
+	}
+	for _, h := range headers {
+		fmt.Fprintf(&buf, "%s: %s\r\n", h.key, h.value)
+	}
+	buf.WriteString("\r\n")
+
+	// Clients display the last part they support, so HTML goes last
+	if err := writePart(mw, "text/plain", m.Text); err != nil {
+		return nil, err
+	}
+	if err := writePart(mw, "text/html", m.HTML); err != nil {
+		return nil, err
+	}
+
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func writePart(mw *multipart.Writer, contentType, body string) error {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", contentType+"; charset=\"UTF-8\"")
+	header.Set("Content-Transfer-Encoding", "quoted-printable")
+
+	part, err := mw.CreatePart(header)
+	if err != nil {
+		return err
+	}
+
+	qp := quotedprintable.NewWriter(part)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// formatAddress parses an address such as "Social <noreply@example.com>" and
+// formats it with the display name encoded when needed.
+func formatAddress(address string) (string, error) {
+	addr, err := mail.ParseAddress(address)
+	if err != nil {
+		return "", err
+	}
+	return addr.String(), nil
+}
+
+// addressDomain returns the domain of an email address, if any
+func addressDomain(address string) string {
+	if addr, err := mail.ParseAddress(address); err == nil {
+		address = addr.Address
+	}
+	if _, domain, found := strings.Cut(address, "@"); found {
+		return domain
+	}
+	return "localhost"
+}
+
+func newMessageID(from string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("<%s.%d@%s>", hex.EncodeToString(b), time.Now().Unix(), addressDomain(from)), nil
+}
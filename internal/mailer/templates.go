@@ -1,6 +1,124 @@
 package mailer
 
-import "embed"
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+)
 
 //go:embed templates/*
 var templates embed.FS
+
+// DefaultLocale is used when an email has no locale or no variant exists for it
+const DefaultLocale = "en"
+
+// Localized is implemented by template data that carries the recipient's locale
+type Localized interface {
+	EmailLocale() string
+}
+
+// Templates holds every email template, parsed once. Templates are named
+// <name>.<locale>.html with a matching <name>.<locale>.txt plain text variant.
+type Templates struct {
+	html map[string]*htmltemplate.Template
+	text map[string]*texttemplate.Template
+}
+
+// LoadTemplates parses all embedded email templates
+func LoadTemplates() (*Templates, error) {
+	t := &Templates{
+		html: make(map[string]*htmltemplate.Template),
+		text: make(map[string]*texttemplate.Template),
+	}
+
+	entries, err := fs.ReadDir(templates, "templates")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		file := path.Join("templates", entry.Name())
+		ext := path.Ext(entry.Name())
+		key := strings.ToLower(strings.TrimSuffix(entry.Name(), ext))
+
+		if strings.Count(key, ".") != 1 {
+			return nil, fmt.Errorf("template %s is not named <name>.<locale>%s", entry.Name(), ext)
+		}
+
+		switch ext {
+		case ".html":
+			tmpl, err := htmltemplate.ParseFS(templates, file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse template: %w", err)
+			}
+			t.html[key] = tmpl
+		case ".txt":
+			tmpl, err := texttemplate.ParseFS(templates, file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse template: %w", err)
+			}
+			t.text[key] = tmpl
+		}
+	}
+
+	for key := range t.html {
+		if _, ok := t.text[key]; !ok {
+			return nil, fmt.Errorf("template %s.html has no plain text variant", key)
+		}
+	}
+
+	return t, nil
+}
+
+// Render executes the HTML and plain text variants of the named template,
+// picking the locale from data when it implements Localized.
+func (t *Templates) Render(name string, data any) (html, text string, err error) {
+	var locale string
+	if l, ok := data.(Localized); ok {
+		locale = l.EmailLocale()
+	}
+
+	key, ok := t.resolve(name, locale)
+	if !ok {
+		return "", "", fmt.Errorf("unknown email template %q", name)
+	}
+
+	var htmlBody, textBody bytes.Buffer
+	if err := t.html[key].Execute(&htmlBody, data); err != nil {
+		return "", "", fmt.Errorf("failed to execute template: %w", err)
+	}
+	if err := t.text[key].Execute(&textBody, data); err != nil {
+		return "", "", fmt.Errorf("failed to execute template: %w", err)
+	}
+
+	return htmlBody.String(), textBody.String(), nil
+}
+
+// resolve finds the best variant of name for locale, trying the full locale
+// ("pt-BR"), then its language ("pt"), then DefaultLocale.
+func (t *Templates) resolve(name, locale string) (string, bool) {
+	locale = strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+
+	candidates := []string{locale}
+	if lang, _, found := strings.Cut(locale, "-"); found {
+		candidates = append(candidates, lang)
+	}
+	candidates = append(candidates, DefaultLocale)
+
+	for _, c := range candidates {
+		if c == "" {
+			continue
+		}
+		key := strings.ToLower(name) + "." + c
+		if _, ok := t.html[key]; ok {
+			return key, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,181 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/moabdelazem/social/internal/mailer"
+	"github.com/moabdelazem/social/internal/store"
+	"go.uber.org/zap"
+)
+
+// Config controls how queued emails are delivered
+type Config struct {
+	Workers      int           // number of concurrent deliveries
+	BatchSize    int           // messages claimed per poll
+	PollInterval time.Duration // delay between polls when the queue is empty
+	Lease        time.Duration // how long a claimed message is reserved for a worker
+	BaseBackoff  time.Duration // delay before the first retry
+	MaxBackoff   time.Duration // upper bound of the retry delay
+	Sandbox      bool          // passed through to mailer.Client.Send
+}
+
+// Validate reports whether the dispatcher can make progress: without workers
+// claimed messages are never delivered, and an empty batch or poll interval
+// turns Run into a busy loop.
+func (c Config) Validate() error {
+	if c.Workers <= 0 {
+		return errors.New("workers must be positive")
+	}
+	if c.BatchSize <= 0 {
+		return errors.New("batch size must be positive")
+	}
+	if c.PollInterval <= 0 {
+		return errors.New("poll interval must be positive")
+	}
+	return nil
+}
+
+// Dispatcher delivers emails from the outbox through a mailer.Client with a
+// pool of workers, retrying failed deliveries with exponential backoff.
+type Dispatcher struct {
+	store  store.Outbox
+	mailer mailer.Client
+	logger *zap.SugaredLogger
+	config Config
+}
+
+// NewDispatcher creates a new outbox dispatcher
+func NewDispatcher(outbox store.Outbox, mailClient mailer.Client, logger *zap.SugaredLogger, config Config) *Dispatcher {
+	return &Dispatcher{
+		store:  outbox,
+		mailer: mailClient,
+		logger: logger,
+		config: config,
+	}
+}
+
+// Run polls the outbox until ctx is cancelled, then waits for the messages
+// already handed to workers. Claimed messages that were not delivered are
+// picked up again once their lease expires.
+func (d *Dispatcher) Run(ctx context.Context) {
+	jobs := make(chan store.OutboxMessage)
+
+	var wg sync.WaitGroup
+	for i := 0; i < d.config.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for msg := range jobs {
+				d.deliver(msg)
+			}
+		}()
+	}
+
+	defer func() {
+		close(jobs)
+		wg.Wait()
+	}()
+
+	for {
+		messages, err := d.store.ClaimDue(ctx, d.config.BatchSize, d.config.Lease)
+		if err != nil && ctx.Err() == nil {
+			d.logger.Errorw("Failed to claim outbox messages", "error", err)
+		}
+
+		for _, msg := range messages {
+			select {
+			case jobs <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		// Poll again right away while the queue keeps filling whole batches
+		if len(messages) == d.config.BatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(d.config.PollInterval):
+		}
+	}
+}
+
+func (d *Dispatcher) deliver(msg store.OutboxMessage) {
+	// Deliveries are not tied to the dispatcher's lifetime so that a message
+	// handed to a worker is always marked as sent or failed.
+	ctx := context.Background()
+
+	var data mailer.EmailData
+	if err := json.Unmarshal(msg.Data, &data); err != nil {
+		d.fail(ctx, msg, err)
+		return
+	}
+
+	if _, err := d.mailer.Send(msg.Recipient, msg.Subject, msg.Template, data, d.config.Sandbox); err != nil {
+		d.fail(ctx, msg, err)
+		return
+	}
+
+	if err := d.store.MarkSent(ctx, msg.ID); err != nil {
+		d.logger.Errorw("Failed to mark outbox message as sent",
+			"error", err,
+			"message_id", msg.ID,
+		)
+		return
+	}
+
+	d.logger.Infow("Email sent",
+		"message_id", msg.ID,
+		"template", msg.Template,
+		"attempts", msg.Attempts,
+	)
+}
+
+func (d *Dispatcher) fail(ctx context.Context, msg store.OutboxMessage, cause error) {
+	retryAt := time.Now().Add(d.backoff(msg.Attempts))
+
+	if err := d.store.MarkFailed(ctx, msg.ID, cause.Error(), retryAt); err != nil {
+		d.logger.Errorw("Failed to mark outbox message as failed",
+			"error", err,
+			"message_id", msg.ID,
+		)
+		return
+	}
+
+	if msg.Attempts >= msg.MaxAttempts {
+		d.logger.Errorw("Email dead-lettered after too many attempts",
+			"error", cause,
+			"message_id", msg.ID,
+			"template", msg.Template,
+			"attempts", msg.Attempts,
+		)
+		return
+	}
+
+	d.logger.Warnw("Email delivery failed, will retry",
+		"error", cause,
+		"message_id", msg.ID,
+		"attempts", msg.Attempts,
+		"retry_at", retryAt,
+	)
+}
+
+// backoff returns the delay before the next attempt: BaseBackoff doubled for
+// every previous attempt, capped at MaxBackoff, with up to 20% jitter.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.config.BaseBackoff
+	for i := 1; i < attempts && delay < d.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, d.config.MaxBackoff)
+
+	return delay + time.Duration(rand.Int63n(int64(delay)/5+1))
+}
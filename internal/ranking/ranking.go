@@ -0,0 +1,68 @@
+package ranking
+
+import (
+	"math"
+	"time"
+)
+
+// Signals are what a Ranker knows about a post when scoring it for a viewer
+type Signals struct {
+	CreatedAt    time.Time
+	Comments     int
+	Reactions    int
+	Interactions int // how often the viewer interacted with the post's author
+}
+
+// Score is the outcome of ranking a post. Total orders the feed, the other
+// fields break it down so that the ranking can be tuned.
+type Score struct {
+	Total     float64 `json:"total"`
+	Recency   float64 `json:"recency"`
+	Comments  float64 `json:"comments"`
+	Reactions float64 `json:"reactions"`
+	Affinity  float64 `json:"affinity"`
+}
+
+// Ranker scores feed posts, higher scores rank first. Weighted is the
+// default; other rankers (e.g. a learned model) implement the same interface.
+type Ranker interface {
+	Score(s Signals, now time.Time) Score
+}
+
+// Weighted ranks posts by engagement and author affinity, decayed by age:
+//
+//	total = recency * (1 + comments + reactions + affinity)
+//
+// recency halves every HalfLife, and every other component grows with the
+// logarithm of its signal so that a handful of interactions matters more
+// than the thousandth one.
+type Weighted struct {
+	HalfLife       time.Duration
+	CommentWeight  float64
+	ReactionWeight float64
+	AffinityWeight float64
+}
+
+// NewWeighted returns a Weighted ranker with the default weights
+func NewWeighted() *Weighted {
+	return &Weighted{
+		HalfLife:       12 * time.Hour,
+		CommentWeight:  1,
+		ReactionWeight: 0.5,
+		AffinityWeight: 1.5,
+	}
+}
+
+func (w *Weighted) Score(s Signals, now time.Time) Score {
+	age := max(now.Sub(s.CreatedAt), 0)
+
+	score := Score{
+		Recency:   math.Exp2(-age.Hours() / w.HalfLife.Hours()),
+		Comments:  w.CommentWeight * math.Log1p(float64(s.Comments)),
+		Reactions: w.ReactionWeight * math.Log1p(float64(s.Reactions)),
+		Affinity:  w.AffinityWeight * math.Log1p(float64(s.Interactions)),
+	}
+	score.Total = score.Recency * (1 + score.Comments + score.Reactions + score.Affinity)
+
+	return score
+}
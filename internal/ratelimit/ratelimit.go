@@ -0,0 +1,42 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Config describes a token bucket: up to Requests requests may be made in a
+// burst, and the bucket refills completely over Window.
+type Config struct {
+	Requests int
+	Window   time.Duration
+}
+
+// Validate reports whether the bucket can refill. Limiters divide by both
+// fields, so invalid configs must be rejected before they are used.
+func (c Config) Validate() error {
+	if c.Requests <= 0 {
+		return errors.New("requests must be positive")
+	}
+	if c.Window <= 0 {
+		return errors.New("window must be positive")
+	}
+	return nil
+}
+
+// Result is the outcome of a single rate limit check
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // time until the bucket is full again
+	RetryAfter time.Duration // time until the next request is allowed, zero when Allowed
+}
+
+// Limiter is the counter store behind the rate limiting middleware.
+// MemoryLimiter keeps buckets in process; a shared backend (e.g. Redis)
+// implements the same interface so that limits hold across replicas.
+type Limiter interface {
+	Allow(ctx context.Context, key string, cfg Config) (Result, error)
+}
@@ -3,15 +3,19 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"strconv"
 	"time"
 )
 
 type Comment struct {
 	ID        int64     `json:"id"`
-	Content   string    `json:"string"`
+	Content   string    `json:"content"`
 	UserID    int64     `json:"user_id"`
 	PostID    int64     `json:"post_id"`
 	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Version   int       `json:"version"`
 	User      User      `json:"user"`
 }
 
@@ -19,18 +23,21 @@ type CommentStore struct {
 	db *sql.DB
 }
 
-func (s *CommentStore) GetByPostID(ctx context.Context, postID int64) ([]Comment, error) {
+// GetByPostID returns all of a post's comments, oldest first, leaving out
+// the comments of users hidden from viewerID
+func (s *CommentStore) GetByPostID(ctx context.Context, postID, viewerID int64) ([]Comment, error) {
 	query := `
-		SELECT c.id, c.post_id, c.user_id, c.content, c.created_at, u.username, u.id  
+		SELECT c.id, c.post_id, c.user_id, c.content, c.created_at, c.updated_at, c.version, u.username, u.id, u.display_name, u.avatar_url
 		FROM comments c
 		INNER JOIN users u ON u.id = c.user_id
-		WHERE c.post_id = $1
+		WHERE c.post_id = $1` + hiddenUsersCondition("c.user_id", "$2") + `
+		ORDER BY c.created_at ASC, c.id ASC
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, query, postID)
+	rows, err := s.db.QueryContext(ctx, query, postID, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -40,7 +47,7 @@ func (s *CommentStore) GetByPostID(ctx context.Context, postID int64) ([]Comment
 	for rows.Next() {
 		var c Comment
 		c.User = User{}
-		err := rows.Scan(&c.ID, &c.PostID, &c.UserID, &c.Content, &c.CreatedAt, &c.User.Username, &c.User.ID)
+		err := rows.Scan(&c.ID, &c.PostID, &c.UserID, &c.Content, &c.CreatedAt, &c.UpdatedAt, &c.Version, &c.User.Username, &c.User.ID, &c.User.DisplayName, &c.User.AvatarURL)
 		if err != nil {
 			return nil, err
 		}
@@ -49,3 +56,185 @@ func (s *CommentStore) GetByPostID(ctx context.Context, postID int64) ([]Comment
 
 	return comments, nil
 }
+
+// ListByPostID returns a page of a post's comments, oldest first, starting
+// after the cursor in cq. Comments of users hidden from viewerID are left
+// out. The returned cursor is empty on the last page.
+func (s *CommentStore) ListByPostID(ctx context.Context, postID, viewerID int64, cq PaginatedCursorQuery) ([]Comment, string, error) {
+	query := `
+		SELECT c.id, c.post_id, c.user_id, c.content, c.created_at, c.updated_at, c.version, u.username, u.id, u.display_name, u.avatar_url
+		FROM comments c
+		INNER JOIN users u ON u.id = c.user_id
+		WHERE c.post_id = $1` + hiddenUsersCondition("c.user_id", "$2")
+
+	args := []interface{}{postID, viewerID}
+
+	if cq.Cursor != "" {
+		cursor, err := DecodeCursor(cq.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		query += ` AND (c.created_at, c.id) > ($3, $4)`
+		args = append(args, cursor.CreatedAt, cursor.ID)
+	}
+
+	// Fetch one extra row to know whether another page exists
+	query += `
+		ORDER BY c.created_at ASC, c.id ASC
+		LIMIT $` + strconv.Itoa(len(args)+1)
+	args = append(args, cq.Limit+1)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	comments := make([]Comment, 0, cq.Limit)
+	for rows.Next() {
+		var c Comment
+		err := rows.Scan(&c.ID, &c.PostID, &c.UserID, &c.Content, &c.CreatedAt, &c.UpdatedAt, &c.Version, &c.User.Username, &c.User.ID, &c.User.DisplayName, &c.User.AvatarURL)
+		if err != nil {
+			return nil, "", err
+		}
+		comments = append(comments, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	var next string
+	if len(comments) > cq.Limit {
+		comments = comments[:cq.Limit]
+		last := comments[len(comments)-1]
+		next = Cursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+	}
+
+	return comments, next, nil
+}
+
+func (s *CommentStore) GetByID(ctx context.Context, id int64) (*Comment, error) {
+	query := `
+		SELECT c.id, c.post_id, c.user_id, c.content, c.created_at, c.updated_at, c.version, u.username, u.id, u.display_name, u.avatar_url
+		FROM comments c
+		INNER JOIN users u ON u.id = c.user_id
+		INNER JOIN posts p ON p.id = c.post_id
+		WHERE c.id = $1` + livePostsCondition("p")
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var c Comment
+	err := s.db.QueryRowContext(ctx, query, id).Scan(
+		&c.ID,
+		&c.PostID,
+		&c.UserID,
+		&c.Content,
+		&c.CreatedAt,
+		&c.UpdatedAt,
+		&c.Version,
+		&c.User.Username,
+		&c.User.ID,
+		&c.User.DisplayName,
+		&c.User.AvatarURL,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrorNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &c, nil
+}
+
+func (s *CommentStore) Create(ctx context.Context, comment *Comment) error {
+	query := `
+		INSERT INTO comments (post_id, user_id, content)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, updated_at, version
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(
+		ctx,
+		query,
+		comment.PostID,
+		comment.UserID,
+		comment.Content,
+	).Scan(
+		&comment.ID,
+		&comment.CreatedAt,
+		&comment.UpdatedAt,
+		&comment.Version,
+	)
+}
+
+// Update saves the content of comment if it is still at the version it was
+// read at. It returns ErrorNotFound when the comment was deleted or edited in
+// the meantime.
+func (s *CommentStore) Update(ctx context.Context, comment *Comment) error {
+	query := `
+		UPDATE comments
+		SET content = $1, version = version + 1, updated_at = NOW()
+		WHERE id = $2 AND version = $3
+		RETURNING version, updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(
+		ctx,
+		query,
+		comment.Content,
+		comment.ID,
+		comment.Version,
+	).Scan(
+		&comment.Version,
+		&comment.UpdatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrorNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *CommentStore) Delete(ctx context.Context, commentID int64) error {
+	query := `
+		DELETE FROM comments WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, commentID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrorNotFound
+	}
+
+	return nil
+}
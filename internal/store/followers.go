@@ -3,6 +3,9 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"strconv"
+	"time"
 
 	"github.com/lib/pq"
 )
@@ -13,52 +16,301 @@ type Follower struct {
 	CreatedAt  string `json:"created_at"`
 }
 
+// FollowEntry is a user in a followers or following list
+type FollowEntry struct {
+	User           User
+	FollowedAt     time.Time
+	IsFollowedByMe bool // whether the viewer follows User
+}
+
+// FollowCounts are the sizes of a user's followers and following lists
+type FollowCounts struct {
+	Followers int `json:"followers_count"`
+	Following int `json:"following_count"`
+}
+
 type FollowerStore struct {
 	db *sql.DB
 }
 
-func (s *FollowerStore) Follow(ctx context.Context, followerID, userID int64) error {
+// Follow makes followerID follow userID and backfills the follower's timeline
+// with userID's latest posts. When userID is a private account a follow
+// request is created instead and Follow returns true. It returns ErrorBlocked
+// when either user blocked the other.
+func (s *FollowerStore) Follow(ctx context.Context, followerID, userID int64) (bool, error) {
+	requested := false
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		blocked, err := isBlocked(ctx, tx, followerID, userID)
+		if err != nil {
+			return err
+		}
+		if blocked {
+			return ErrorBlocked
+		}
+
+		var isPrivate bool
+		err = tx.QueryRowContext(ctx, `SELECT is_private FROM users WHERE id = $1 FOR SHARE`, userID).Scan(&isPrivate)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrorNotFound
+			}
+			return err
+		}
+
+		if isPrivate {
+			requested = true
+			return requestFollow(ctx, tx, followerID, userID)
+		}
+
+		return follow(ctx, tx, followerID, userID)
+	})
+
+	return requested, err
+}
+
+func follow(ctx context.Context, tx *sql.Tx, followerID, userID int64) error {
 	query := `
 		INSERT INTO followers (user_id, follower_id)
 		VALUES ($1, $2)
 	`
 
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
-	defer cancel()
-
-	_, err := s.db.ExecContext(ctx, query, userID, followerID)
+	_, err := tx.ExecContext(ctx, query, userID, followerID)
 	if err != nil {
 		// Check for unique constraint violation (duplicate follow)
 		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
 			return ErrorConflict
 		}
+		return err
 	}
-	return err
+
+	return backfillTimeline(ctx, tx, followerID, userID)
 }
 
-func (s *FollowerStore) Unfollow(ctx context.Context, followerID, userID int64) error {
-	query := `
-		DELETE FROM followers
-		WHERE user_id = $1 AND follower_id = $2
+func requestFollow(ctx context.Context, tx *sql.Tx, followerID, userID int64) error {
+	var following bool
+	query := `SELECT EXISTS (SELECT 1 FROM followers WHERE user_id = $1 AND follower_id = $2)`
+	if err := tx.QueryRowContext(ctx, query, userID, followerID).Scan(&following); err != nil {
+		return err
+	}
+	if following {
+		return ErrorConflict
+	}
+
+	query = `
+		INSERT INTO follow_requests (user_id, requester_id)
+		VALUES ($1, $2)
 	`
 
+	_, err := tx.ExecContext(ctx, query, userID, followerID)
+	if err != nil {
+		// Check for unique constraint violation (duplicate request)
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrorConflict
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Unfollow removes the follow and userID's posts from the follower's
+// timeline, or withdraws the pending follow request
+func (s *FollowerStore) Unfollow(ctx context.Context, followerID, userID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `
+			DELETE FROM followers
+			WHERE user_id = $1 AND follower_id = $2
+		`
+		res, err := tx.ExecContext(ctx, query, userID, followerID)
+		if err != nil {
+			return err
+		}
+
+		// Check if any rows were affected
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows > 0 {
+			return clearTimeline(ctx, tx, followerID, userID)
+		}
+
+		deleted, err := deleteFollowRequest(ctx, tx, userID, followerID)
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			return ErrorNotFollowing
+		}
+
+		return nil
+	})
+}
+
+// ListRequests returns a page of the pending requests to follow userID, most
+// recent first. IsFollowedByMe tells whether userID follows the requester.
+func (s *FollowerStore) ListRequests(ctx context.Context, userID int64, cq PaginatedCursorQuery) ([]FollowEntry, string, error) {
+	return s.list(ctx, "follow_requests", "user_id", "requester_id", userID, userID, cq)
+}
+
+// ApproveRequest turns requesterID's pending request into a follow of userID
+func (s *FollowerStore) ApproveRequest(ctx context.Context, userID, requesterID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		deleted, err := deleteFollowRequest(ctx, tx, userID, requesterID)
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			return ErrorNotFound
+		}
+
+		return follow(ctx, tx, requesterID, userID)
+	})
+}
+
+// RejectRequest deletes requesterID's pending request to follow userID
+func (s *FollowerStore) RejectRequest(ctx context.Context, userID, requesterID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		deleted, err := deleteFollowRequest(ctx, tx, userID, requesterID)
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			return ErrorNotFound
+		}
+
+		return nil
+	})
+}
+
+func deleteFollowRequest(ctx context.Context, tx *sql.Tx, userID, requesterID int64) (bool, error) {
+	query := `DELETE FROM follow_requests WHERE user_id = $1 AND requester_id = $2`
+
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	res, err := s.db.ExecContext(ctx, query, userID, followerID)
+	res, err := tx.ExecContext(ctx, query, userID, requesterID)
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	// Check if any rows were affected
 	rows, err := res.RowsAffected()
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	if rows == 0 {
-		return ErrorNotFollowing
+	return rows > 0, nil
+}
+
+// ListFollowers returns a page of the users following userID, most recent
+// follows first
+func (s *FollowerStore) ListFollowers(ctx context.Context, userID, viewerID int64, cq PaginatedCursorQuery) ([]FollowEntry, string, error) {
+	return s.list(ctx, "followers", "user_id", "follower_id", userID, viewerID, cq)
+}
+
+// ListFollowing returns a page of the users userID follows, most recent
+// follows first
+func (s *FollowerStore) ListFollowing(ctx context.Context, userID, viewerID int64, cq PaginatedCursorQuery) ([]FollowEntry, string, error) {
+	return s.list(ctx, "followers", "follower_id", "user_id", userID, viewerID, cq)
+}
+
+// list pages through the rows of table (followers or follow_requests) whose
+// ownerColumn is userID, listing the users in listedColumn. Cursors point at
+// (created_at, listed user ID).
+func (s *FollowerStore) list(ctx context.Context, table, ownerColumn, listedColumn string, userID, viewerID int64, cq PaginatedCursorQuery) ([]FollowEntry, string, error) {
+	query := `
+		SELECT u.id, u.username, u.display_name, u.bio, u.avatar_url, u.is_private, u.created_at, f.created_at,
+			EXISTS (SELECT 1 FROM followers me WHERE me.user_id = u.id AND me.follower_id = $2)
+		FROM ` + table + ` f
+		INNER JOIN users u ON u.id = f.` + listedColumn + `
+		WHERE f.` + ownerColumn + ` = $1`
+
+	args := []interface{}{userID, viewerID}
+
+	if cq.Cursor != "" {
+		cursor, err := DecodeCursor(cq.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		query += ` AND (f.created_at, f.` + listedColumn + `) < ($3, $4)`
+		args = append(args, cursor.CreatedAt, cursor.ID)
 	}
 
-	return nil
+	// Fetch one extra row to know whether another page exists
+	query += `
+		ORDER BY f.created_at DESC, f.` + listedColumn + ` DESC
+		LIMIT $` + strconv.Itoa(len(args)+1)
+	args = append(args, cq.Limit+1)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	entries := make([]FollowEntry, 0, cq.Limit)
+	for rows.Next() {
+		var e FollowEntry
+		err := rows.Scan(
+			&e.User.ID,
+			&e.User.Username,
+			&e.User.DisplayName,
+			&e.User.Bio,
+			&e.User.AvatarURL,
+			&e.User.IsPrivate,
+			&e.User.CreatedAt,
+			&e.FollowedAt,
+			&e.IsFollowedByMe,
+		)
+		if err != nil {
+			return nil, "", err
+		}
+		entries = append(entries, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	var next string
+	if len(entries) > cq.Limit {
+		entries = entries[:cq.Limit]
+		last := entries[len(entries)-1]
+		next = Cursor{CreatedAt: last.FollowedAt, ID: last.User.ID}.Encode()
+	}
+
+	return entries, next, nil
+}
+
+// GetCounts returns how many users follow userID and how many it follows
+func (s *FollowerStore) GetCounts(ctx context.Context, userID int64) (*FollowCounts, error) {
+	query := `
+		SELECT
+			(SELECT COUNT(*) FROM followers WHERE user_id = $1),
+			(SELECT COUNT(*) FROM followers WHERE follower_id = $1)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	counts := &FollowCounts{}
+	if err := s.db.QueryRowContext(ctx, query, userID).Scan(&counts.Followers, &counts.Following); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
 }
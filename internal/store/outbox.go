@@ -0,0 +1,237 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+const (
+	OutboxStatusPending = "pending"
+	OutboxStatusSending = "sending"
+	OutboxStatusSent    = "sent"
+	OutboxStatusFailed  = "failed"
+)
+
+// OutboxMessage is an email queued for delivery. Data holds the JSON encoded
+// template data and is never serialized in API responses since it may carry
+// one-time tokens. It is cleared once the message is sent or dead-lettered.
+type OutboxMessage struct {
+	ID            int64           `json:"id"`
+	Recipient     string          `json:"recipient"`
+	Subject       string          `json:"subject"`
+	Template      string          `json:"template"`
+	Data          json.RawMessage `json:"-"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	MaxAttempts   int             `json:"max_attempts"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	LastError     *string         `json:"last_error"`
+	SentAt        *time.Time      `json:"sent_at"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
+
+type OutboxStore struct {
+	db *sql.DB
+}
+
+// Enqueue queues an email that is not tied to any other write
+func (s *OutboxStore) Enqueue(ctx context.Context, msg *OutboxMessage) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return enqueueEmail(ctx, tx, msg)
+	})
+}
+
+// enqueueEmail queues an email as part of a larger transaction so that the
+// email is only sent if the rest of the transaction commits.
+func enqueueEmail(ctx context.Context, tx *sql.Tx, msg *OutboxMessage) error {
+	query := `
+		INSERT INTO email_outbox (recipient, subject, template, data)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, status, attempts, max_attempts, next_attempt_at, created_at, updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return tx.QueryRowContext(ctx, query, msg.Recipient, msg.Subject, msg.Template, string(msg.Data)).Scan(
+		&msg.ID,
+		&msg.Status,
+		&msg.Attempts,
+		&msg.MaxAttempts,
+		&msg.NextAttemptAt,
+		&msg.CreatedAt,
+		&msg.UpdatedAt,
+	)
+}
+
+// ClaimDue locks up to limit messages that are due for delivery and leases
+// them to the caller. Messages whose lease expires without being marked sent
+// or failed become due again, so a crashed worker never loses mail, until
+// they run out of attempts and are dead-lettered.
+// Concurrent callers never claim the same message.
+func (s *OutboxStore) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]OutboxMessage, error) {
+	var messages []OutboxMessage
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		// A message that keeps crashing or timing out its worker is given up on
+		query := `
+			UPDATE email_outbox
+			SET status = 'failed', data = '{}', last_error = 'delivery lease expired', updated_at = NOW()
+			WHERE status = 'sending' AND next_attempt_at <= NOW() AND attempts >= max_attempts
+		`
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return err
+		}
+
+		query = `
+			UPDATE email_outbox o
+			SET status = 'sending', attempts = o.attempts + 1, next_attempt_at = $2, updated_at = NOW()
+			WHERE o.id IN (
+				SELECT id FROM email_outbox
+				WHERE status IN ('pending', 'sending') AND next_attempt_at <= NOW() AND attempts < max_attempts
+				ORDER BY next_attempt_at
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING o.id, o.recipient, o.subject, o.template, o.data, o.status, o.attempts, o.max_attempts,
+				o.next_attempt_at, o.last_error, o.sent_at, o.created_at, o.updated_at
+		`
+
+		rows, err := tx.QueryContext(ctx, query, limit, time.Now().Add(lease))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		messages, err = scanOutboxMessages(rows)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// MarkSent records a delivered message and drops its template data, which
+// is no longer needed and may hold activation or reset tokens
+func (s *OutboxStore) MarkSent(ctx context.Context, id int64) error {
+	query := `
+		UPDATE email_outbox
+		SET status = 'sent', sent_at = NOW(), last_error = NULL, data = '{}', updated_at = NOW()
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, id)
+	return err
+}
+
+// MarkFailed records a failed delivery attempt. The message is retried at
+// retryAt, unless it ran out of attempts, in which case it is dead-lettered
+// with the failed status and its template data is dropped.
+func (s *OutboxStore) MarkFailed(ctx context.Context, id int64, reason string, retryAt time.Time) error {
+	query := `
+		UPDATE email_outbox
+		SET status = CASE WHEN attempts >= max_attempts THEN 'failed' ELSE 'pending' END,
+			data = CASE WHEN attempts >= max_attempts THEN '{}' ELSE data END,
+			last_error = $2, next_attempt_at = $3, updated_at = NOW()
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, id, reason, retryAt)
+	return err
+}
+
+// ListFailed returns dead-lettered messages, newest first
+func (s *OutboxStore) ListFailed(ctx context.Context, cq PaginatedCursorQuery) ([]OutboxMessage, string, error) {
+	query := `
+		SELECT id, recipient, subject, template, data, status, attempts, max_attempts,
+			next_attempt_at, last_error, sent_at, created_at, updated_at
+		FROM email_outbox
+		WHERE status = 'failed'`
+
+	args := []interface{}{}
+
+	if cq.Cursor != "" {
+		cursor, err := DecodeCursor(cq.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		query += ` AND (created_at, id) < ($1, $2)`
+		args = append(args, cursor.CreatedAt, cursor.ID)
+	}
+
+	// Fetch one extra row to know whether another page exists
+	query += `
+		ORDER BY created_at DESC, id DESC
+		LIMIT $` + strconv.Itoa(len(args)+1)
+	args = append(args, cq.Limit+1)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	messages, err := scanOutboxMessages(rows)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var next string
+	if len(messages) > cq.Limit {
+		messages = messages[:cq.Limit]
+		last := messages[len(messages)-1]
+		next = Cursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+	}
+
+	return messages, next, nil
+}
+
+func scanOutboxMessages(rows *sql.Rows) ([]OutboxMessage, error) {
+	messages := make([]OutboxMessage, 0)
+	for rows.Next() {
+		var m OutboxMessage
+		err := rows.Scan(
+			&m.ID,
+			&m.Recipient,
+			&m.Subject,
+			&m.Template,
+			&m.Data,
+			&m.Status,
+			&m.Attempts,
+			&m.MaxAttempts,
+			&m.NextAttemptAt,
+			&m.LastError,
+			&m.SentAt,
+			&m.CreatedAt,
+			&m.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
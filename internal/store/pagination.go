@@ -1,15 +1,109 @@
 package store
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 )
 
+var ErrorInvalidCursor = errors.New("invalid cursor")
+
+// Cursor points at a row of a keyset paginated listing ordered by (created_at, id).
+// Prev cursors select the page before the row instead of the page after it.
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        int64     `json:"id"`
+	Prev      bool      `json:"p,omitempty"`
+}
+
+// PageCursors hold the cursors of the pages around the current one.
+// An empty cursor means there is no page in that direction.
+type PageCursors struct {
+	Next string `json:"next_cursor,omitempty"`
+	Prev string `json:"prev_cursor,omitempty"`
+}
+
+// Pagination is the metadata returned alongside a page of a listing. Offset
+// is only set for offset paginated pages, Cursor only for keyset ones.
+type Pagination struct {
+	Limit  int    `json:"limit"`
+	Offset *int   `json:"offset,omitempty"`
+	Cursor string `json:"cursor,omitempty"`
+	PageCursors
+	HasMore bool `json:"has_more"`
+}
+
+// Encode returns the opaque representation of the cursor handed out to clients
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor parses a cursor previously returned by Cursor.Encode
+func DecodeCursor(s string) (Cursor, error) {
+	var c Cursor
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrorInvalidCursor
+	}
+
+	if err := json.Unmarshal(b, &c); err != nil || c.ID == 0 {
+		return c, ErrorInvalidCursor
+	}
+
+	return c, nil
+}
+
+type PaginatedCursorQuery struct {
+	Limit  int    `json:"limit" validate:"gte=1,lte=50"`
+	Cursor string `json:"cursor"`
+}
+
+func (cq PaginatedCursorQuery) Parse(r *http.Request) (PaginatedCursorQuery, error) {
+	qs := r.URL.Query()
+
+	// Parse limit with default value of 20
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return cq, err
+		}
+		cq.Limit = l
+	} else {
+		cq.Limit = 20
+	}
+
+	// Parse cursor (optional), rejecting tokens we did not issue
+	if cursor := qs.Get("cursor"); cursor != "" {
+		if _, err := DecodeCursor(cursor); err != nil {
+			return cq, err
+		}
+		cq.Cursor = cursor
+	}
+
+	return cq, nil
+}
+
+// Page describes the page fetched with cq
+func (cq PaginatedCursorQuery) Page(cursors PageCursors) Pagination {
+	return Pagination{
+		Limit:       cq.Limit,
+		Cursor:      cq.Cursor,
+		PageCursors: cursors,
+		HasMore:     cursors.Next != "",
+	}
+}
+
 type PaginatedFeedQuery struct {
 	Limit  int      `json:"limit" validate:"gte=1,lte=20"`
 	Offset int      `json:"offset" validate:"gte=0"`
-	Sort   string   `json:"sort" validate:"oneof=asc desc"`
+	Cursor string   `json:"cursor" validate:"excluded_unless=Offset 0,excluded_if=Sort top"`
+	Sort   string   `json:"sort" validate:"oneof=asc desc top"`
 	Search string   `json:"search" validate:"max=100"`
 	Tags   []string `json:"tags" validate:"max=5"`
 	Since  string   `json:"since"`
@@ -43,7 +137,16 @@ func (fq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error)
 		fq.Offset = 0
 	}
 
-	// Parse sort with default value of "desc"
+	// Parse cursor (optional), keyset pagination takes over from offset
+	if cursor := qs.Get("cursor"); cursor != "" {
+		if _, err := DecodeCursor(cursor); err != nil {
+			return fq, err
+		}
+		fq.Cursor = cursor
+	}
+
+	// Parse sort with default value of "desc", "top" ranks posts instead of
+	// ordering them chronologically and only supports offset pagination
 	sort := qs.Get("sort")
 	if sort != "" {
 		fq.Sort = sort
@@ -83,3 +186,21 @@ func (fq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error)
 
 	return fq, nil
 }
+
+// Page describes the page fetched with fq. Pages requested without a cursor
+// report their offset so that offset based clients keep working.
+func (fq PaginatedFeedQuery) Page(cursors PageCursors) Pagination {
+	page := Pagination{
+		Limit:       fq.Limit,
+		Cursor:      fq.Cursor,
+		PageCursors: cursors,
+		HasMore:     cursors.Next != "",
+	}
+
+	if fq.Cursor == "" {
+		offset := fq.Offset
+		page.Offset = &offset
+	}
+
+	return page
+}
@@ -4,155 +4,316 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"slices"
 	"strconv"
 	"time"
 
 	"github.com/lib/pq"
 )
 
+// Post statuses. Drafts and scheduled posts are only visible to their
+// author until they are published.
+const (
+	PostStatusDraft     = "draft"
+	PostStatusScheduled = "scheduled"
+	PostStatusPublished = "published"
+)
+
 type Post struct {
-	ID        int64     `json:"id"`
-	Content   string    `json:"content"`
-	Title     string    `json:"title"`
-	UserID    int64     `json:"user_id"`
-	Tags      []string  `json:"tags"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Comments  []Comment `json:"comments"`
-	Version   int       `json:"version"`
+	ID        int64      `json:"id"`
+	Content   string     `json:"content"`
+	Title     string     `json:"title"`
+	UserID    int64      `json:"user_id"`
+	Tags      []string   `json:"tags"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Comments  []Comment  `json:"comments"`
+	Version   int        `json:"version"`
+	Status    string     `json:"status"`
+	PublishAt *time.Time `json:"publish_at,omitempty"` // when a scheduled post goes out
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 type PostsWithMetaData struct {
 	Post
 	CommentsCount int `json:"comments_count"`
+	PostReactions
 }
 
 type PostStore struct {
 	db *sql.DB
 }
 
-func (s *PostStore) GetUserFeed(ctx context.Context, userId int64, fq PaginatedFeedQuery) ([]PostsWithMetaData, error) {
-	// Build dynamic query with filters
+// GetUserFeed returns a page of posts written by the users userId follows,
+// joining followers at read time (fan-out-on-read). Muted and blocked users
+// are left out. Pages are selected with fq.Cursor (keyset pagination on
+// created_at, id) or, for backward compatibility, with fq.Offset.
+func (s *PostStore) GetUserFeed(ctx context.Context, userId int64, fq PaginatedFeedQuery) ([]PostsWithMetaData, PageCursors, error) {
 	query := `
 		SELECT 
-			p.id, p.user_id, p.title, p.content, p.created_at, p.updated_at, p.tags, p.version,
-			COUNT(c.id) AS comments_count
+			p.id, p.user_id, p.title, p.content, p.created_at, p.updated_at, p.tags, p.version, p.status, p.publish_at,
+			(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id) AS comments_count,` + reactionsColumns("p.id", "$1") + `
 		FROM posts p
-		LEFT JOIN comments c ON c.post_id = p.id
 		INNER JOIN followers f ON f.user_id = p.user_id
-		WHERE f.follower_id = $1`
+		WHERE f.follower_id = $1` + publishedPostsCondition("p") + hiddenUsersCondition("p.user_id", "$1")
+
+	query, args, cursor, err := paginatePostsQuery(query, []interface{}{userId}, fq)
+	if err != nil {
+		return nil, PageCursors{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, PageCursors{}, err
+	}
+	defer rows.Close()
+
+	feed, err := scanPostsWithMetaData(rows)
+	if err != nil {
+		return nil, PageCursors{}, err
+	}
+
+	feed, cursors := postsPage(feed, fq, cursor, PostsWithMetaData.cursor)
+	return feed, cursors, nil
+}
+
+// livePostsCondition returns a condition filtering out posts in the trash,
+// alias being the alias of the posts table in the query
+func livePostsCondition(alias string) string {
+	return ` AND ` + alias + `.deleted_at IS NULL`
+}
+
+// publishedPostsCondition returns a condition keeping the published posts
+// that are not in the trash, which is what everyone but their author sees
+func publishedPostsCondition(alias string) string {
+	return livePostsCondition(alias) + ` AND ` + alias + `.status = 'published'`
+}
+
+func scanPostsWithMetaData(rows *sql.Rows) ([]PostsWithMetaData, error) {
+	feed := make([]PostsWithMetaData, 0)
+	for rows.Next() {
+		var p PostsWithMetaData
+		err := rows.Scan(
+			&p.ID,
+			&p.UserID,
+			&p.Title,
+			&p.Content,
+			&p.CreatedAt,
+			&p.UpdatedAt,
+			pq.Array(&p.Tags),
+			&p.Version,
+			&p.Status,
+			&p.PublishAt,
+			&p.CommentsCount,
+			&p.Reactions,
+			pq.Array(&p.MyReactions),
+		)
+		if err != nil {
+			return nil, err
+		}
+		feed = append(feed, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return feed, nil
+}
+
+// paginatePostsQuery appends the filters, keyset condition, ordering and
+// limit of fq to a query over posts aliased as p. It returns the decoded
+// cursor the page starts from, which postsPage needs to finish the page.
+func paginatePostsQuery(query string, args []interface{}, fq PaginatedFeedQuery) (string, []interface{}, Cursor, error) {
+	conditions, args, cursor, sort, err := postsPageConditions(args, fq, "p.created_at", "p.id")
+	if err != nil {
+		return "", nil, cursor, err
+	}
+
+	// Add ORDER BY, LIMIT, and OFFSET. One extra row tells whether more pages follow.
+	paramIndex := len(args) + 1
+	query += conditions + `
+		ORDER BY p.created_at ` + sort + `, p.id ` + sort + `
+		LIMIT $` + strconv.Itoa(paramIndex) + ` OFFSET $` + strconv.Itoa(paramIndex+1)
+
+	args = append(args, fq.Limit+1, fq.Offset)
+
+	return query, args, cursor, nil
+}
+
+// postsPageConditions returns the filters of fq over posts aliased as p and
+// its keyset condition on the createdAt and id columns, along with the
+// decoded cursor and the order rows must be scanned in.
+func postsPageConditions(args []interface{}, fq PaginatedFeedQuery, createdAt, id string) (string, []interface{}, Cursor, string, error) {
+	var conditions string
 
 	// Dynamic query params
-	args := []interface{}{userId}
-	paramIndex := 2
+	paramIndex := len(args) + 1
 
 	// Add search filter (search in title and content)
 	if fq.Search != "" {
-		query += ` AND (p.title ILIKE $` + strconv.Itoa(paramIndex) + ` OR p.content ILIKE $` + strconv.Itoa(paramIndex) + `)`
+		conditions += ` AND (p.title ILIKE $` + strconv.Itoa(paramIndex) + ` OR p.content ILIKE $` + strconv.Itoa(paramIndex) + `)`
 		args = append(args, "%"+fq.Search+"%")
 		paramIndex++
 	}
 
 	// Add tags filter (posts must contain all specified tags)
 	if len(fq.Tags) > 0 {
-		query += ` AND p.tags @> $` + strconv.Itoa(paramIndex)
+		conditions += ` AND p.tags @> $` + strconv.Itoa(paramIndex)
 		args = append(args, pq.Array(fq.Tags))
 		paramIndex++
 	}
 
 	// Add since filter (posts created after this date)
 	if fq.Since != "" {
-		query += ` AND p.created_at >= $` + strconv.Itoa(paramIndex)
+		conditions += ` AND p.created_at >= $` + strconv.Itoa(paramIndex)
 		args = append(args, fq.Since)
 		paramIndex++
 	}
 
 	// Add until filter (posts created before this date)
 	if fq.Until != "" {
-		query += ` AND p.created_at <= $` + strconv.Itoa(paramIndex)
+		conditions += ` AND p.created_at <= $` + strconv.Itoa(paramIndex)
 		args = append(args, fq.Until)
 		paramIndex++
 	}
 
-	// Add GROUP BY, ORDER BY, LIMIT, and OFFSET
-	query += `
-		GROUP BY p.id
-		ORDER BY p.created_at ` + fq.Sort + `
-		LIMIT $` + strconv.Itoa(paramIndex) + ` OFFSET $` + strconv.Itoa(paramIndex+1)
+	// Walking backwards from a prev cursor reverses the order, the page is
+	// flipped back once fetched
+	var cursor Cursor
+	if fq.Cursor != "" {
+		var err error
+		cursor, err = DecodeCursor(fq.Cursor)
+		if err != nil {
+			return "", nil, cursor, "", err
+		}
+	}
 
-	args = append(args, fq.Limit, fq.Offset)
+	sort := fq.Sort
+	if cursor.Prev {
+		sort = reverseSort(sort)
+	}
 
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
-	defer cancel()
+	// Add keyset filter (rows strictly after the cursor in scan order)
+	if fq.Cursor != "" {
+		op := "<"
+		if sort == "asc" {
+			op = ">"
+		}
+		conditions += ` AND (` + createdAt + `, ` + id + `) ` + op + ` ($` + strconv.Itoa(paramIndex) + `, $` + strconv.Itoa(paramIndex+1) + `)`
+		args = append(args, cursor.CreatedAt, cursor.ID)
+	}
 
-	rows, err := s.db.QueryContext(ctx, query, args...)
-	if err != nil {
-		return nil, err
+	return conditions, args, cursor, sort, nil
+}
+
+// postsPage trims the extra row fetched by paginatePostsQuery, restores the
+// requested order and computes the cursors of the surrounding pages.
+func postsPage[T any](posts []T, fq PaginatedFeedQuery, cursor Cursor, key func(T) Cursor) ([]T, PageCursors) {
+	var cursors PageCursors
+
+	hasMore := len(posts) > fq.Limit
+	if hasMore {
+		posts = posts[:fq.Limit]
 	}
-	defer rows.Close()
 
-	feed := make([]PostsWithMetaData, 0)
-	for rows.Next() {
-		var p PostsWithMetaData
-		err := rows.Scan(
-			&p.ID,
-			&p.UserID,
-			&p.Title,
-			&p.Content,
-			&p.CreatedAt,
-			&p.UpdatedAt,
-			pq.Array(&p.Tags),
-			&p.Version,
-			&p.CommentsCount,
-		)
-		if err != nil {
-			return nil, err
-		}
-		feed = append(feed, p)
+	if cursor.Prev {
+		slices.Reverse(posts)
 	}
 
-	if err = rows.Err(); err != nil {
-		return nil, err
+	if len(posts) == 0 {
+		return posts, cursors
 	}
 
-	return feed, nil
+	// hasMore refers to the direction we walked in. The page we came from
+	// always exists in the opposite direction.
+	hasNext, hasPrev := hasMore, fq.Cursor != "" || fq.Offset > 0
+	if cursor.Prev {
+		hasNext, hasPrev = true, hasMore
+	}
+
+	if hasNext {
+		next := key(posts[len(posts)-1])
+		cursors.Next = next.Encode()
+	}
+	if hasPrev {
+		prev := key(posts[0])
+		prev.Prev = true
+		cursors.Prev = prev.Encode()
+	}
+
+	return posts, cursors
 }
 
-func (s *PostStore) Create(ctx context.Context, post *Post) error {
-	query := `
-	INSERT INTO posts (content, title, user_id, tags)
-	VALUES ($1, $2, $3, $4) RETURNING id, created_at, updated_at, version
-	`
+func reverseSort(sort string) string {
+	if sort == "asc" {
+		return "desc"
+	}
+	return "asc"
+}
 
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
-	defer cancel()
+// cursor returns the keyset position of the post
+func (p Post) cursor() Cursor {
+	return Cursor{CreatedAt: p.CreatedAt, ID: p.ID}
+}
 
-	err := s.db.QueryRowContext(
-		ctx,
-		query,
-		post.Content,
-		post.Title,
-		post.UserID,
-		pq.Array(post.Tags),
-	).Scan(
-		&post.ID,
-		&post.CreatedAt,
-		&post.UpdatedAt,
-		&post.Version,
-	)
-	if err != nil {
-		return err
+// Create inserts post and records it as its first revision. Posts without
+// a status are published right away.
+func (s *PostStore) Create(ctx context.Context, post *Post) error {
+	if post.Status == "" {
+		post.Status = PostStatusPublished
 	}
 
-	return nil
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+		INSERT INTO posts (content, title, user_id, tags, status, publish_at)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at, updated_at, version
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			post.Content,
+			post.Title,
+			post.UserID,
+			pq.Array(post.Tags),
+			post.Status,
+			post.PublishAt,
+		).Scan(
+			&post.ID,
+			&post.CreatedAt,
+			&post.UpdatedAt,
+			&post.Version,
+		)
+		if err != nil {
+			return err
+		}
+
+		return recordRevision(ctx, tx, post)
+	})
 }
 
 func (s *PostStore) GetByID(ctx context.Context, id int64) (*Post, error) {
+	return s.get(ctx, id, livePostsCondition("p"))
+}
+
+// GetTrashedByID returns a post that is in the trash
+func (s *PostStore) GetTrashedByID(ctx context.Context, id int64) (*Post, error) {
+	return s.get(ctx, id, ` AND p.deleted_at IS NOT NULL`)
+}
+
+func (s *PostStore) get(ctx context.Context, id int64, condition string) (*Post, error) {
 	query := `
-		SELECT id, user_id, title, content, created_at, updated_at, tags, version
-	 	FROM posts
-		WHERE id = $1
-	`
+		SELECT p.id, p.user_id, p.title, p.content, p.created_at, p.updated_at, p.tags, p.version, p.status, p.publish_at, p.deleted_at
+	 	FROM posts p
+		WHERE p.id = $1` + condition
+
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
@@ -166,6 +327,9 @@ func (s *PostStore) GetByID(ctx context.Context, id int64) (*Post, error) {
 		&post.UpdatedAt,
 		pq.Array(&post.Tags),
 		&post.Version,
+		&post.Status,
+		&post.PublishAt,
+		&post.DeletedAt,
 	)
 	if err != nil {
 		switch {
@@ -179,23 +343,173 @@ func (s *PostStore) GetByID(ctx context.Context, id int64) (*Post, error) {
 	return &post, nil
 }
 
-func (s *PostStore) GetByUserID(ctx context.Context, userID int64) ([]Post, error) {
+// GetByUserID returns a page of the posts written by userID, paginated and
+// filtered the same way as the feed.
+func (s *PostStore) GetByUserID(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]Post, PageCursors, error) {
 	query := `
-		SELECT id, user_id, title, content, created_at, updated_at, tags, version
-	 	FROM posts
-		WHERE user_id = $1
-		ORDER BY created_at DESC
+		SELECT p.id, p.user_id, p.title, p.content, p.created_at, p.updated_at, p.tags, p.version, p.status, p.publish_at
+	 	FROM posts p
+		WHERE p.user_id = $1` + publishedPostsCondition("p")
+
+	query, args, cursor, err := paginatePostsQuery(query, []interface{}{userID}, fq)
+	if err != nil {
+		return nil, PageCursors{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, PageCursors{}, err
+	}
+	defer rows.Close()
+
+	posts, err := scanPosts(rows)
+	if err != nil {
+		return nil, PageCursors{}, err
+	}
+
+	posts, cursors := postsPage(posts, fq, cursor, Post.cursor)
+	return posts, cursors, nil
+}
+
+// GetAuthorInteractions counts, per author, how often viewerID commented on
+// or reacted to their posts since the given time
+func (s *PostStore) GetAuthorInteractions(ctx context.Context, viewerID int64, since time.Time) (map[int64]int, error) {
+	query := `
+		SELECT p.user_id, COUNT(*)
+		FROM (
+			SELECT c.post_id FROM comments c
+			WHERE c.user_id = $1 AND c.created_at >= $2
+			UNION ALL
+			SELECT r.post_id FROM post_reactions r
+			WHERE r.user_id = $1 AND r.created_at >= $2
+		) i
+		INNER JOIN posts p ON p.id = i.post_id
+		WHERE p.user_id <> $1` + livePostsCondition("p") + `
+		GROUP BY p.user_id
 	`
+
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, query, userID)
+	rows, err := s.db.QueryContext(ctx, query, viewerID, since)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var posts []Post
+	interactions := make(map[int64]int)
+	for rows.Next() {
+		var authorID int64
+		var count int
+		if err := rows.Scan(&authorID, &count); err != nil {
+			return nil, err
+		}
+		interactions[authorID] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return interactions, nil
+}
+
+// Delete moves the post to the trash if it is still at the given version.
+// It returns ErrorNotFound when the post was deleted or edited in the
+// meantime.
+func (s *PostStore) Delete(ctx context.Context, postID int64, version int) error {
+	query := `
+		UPDATE posts SET deleted_at = NOW()
+		WHERE id = $1 AND version = $2 AND deleted_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, postID, version)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrorNotFound
+	}
+
+	return nil
+}
+
+// Restore takes a post out of the trash, returning ErrorNotFound when it is
+// not in the trash
+func (s *PostStore) Restore(ctx context.Context, post *Post) error {
+	query := `
+		UPDATE posts SET deleted_at = NULL
+		WHERE id = $1 AND deleted_at IS NOT NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, post.ID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrorNotFound
+	}
+
+	post.DeletedAt = nil
+	return nil
+}
+
+// GetTrash returns a page of the posts userID deleted, most recently deleted
+// first
+func (s *PostStore) GetTrash(ctx context.Context, userID int64, cq PaginatedCursorQuery) ([]Post, string, error) {
+	query := `
+		SELECT p.id, p.user_id, p.title, p.content, p.created_at, p.updated_at, p.tags, p.version, p.status, p.publish_at, p.deleted_at
+		FROM posts p
+		WHERE p.user_id = $1 AND p.deleted_at IS NOT NULL`
+
+	args := []interface{}{userID}
+
+	if cq.Cursor != "" {
+		cursor, err := DecodeCursor(cq.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		query += ` AND (p.deleted_at, p.id) < ($2, $3)`
+		args = append(args, cursor.CreatedAt, cursor.ID)
+	}
+
+	// Fetch one extra row to know whether another page exists
+	query += `
+		ORDER BY p.deleted_at DESC, p.id DESC
+		LIMIT $` + strconv.Itoa(len(args)+1)
+	args = append(args, cq.Limit+1)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	posts := make([]Post, 0, cq.Limit)
 	for rows.Next() {
 		var post Post
 		err := rows.Scan(
@@ -207,76 +521,232 @@ func (s *PostStore) GetByUserID(ctx context.Context, userID int64) ([]Post, erro
 			&post.UpdatedAt,
 			pq.Array(&post.Tags),
 			&post.Version,
+			&post.Status,
+			&post.PublishAt,
+			&post.DeletedAt,
 		)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 		posts = append(posts, post)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	return posts, nil
+	var next string
+	if len(posts) > cq.Limit {
+		posts = posts[:cq.Limit]
+		last := posts[len(posts)-1]
+		next = Cursor{CreatedAt: *last.DeletedAt, ID: last.ID}.Encode()
+	}
+
+	return posts, next, nil
 }
 
-func (s *PostStore) Delete(ctx context.Context, postID int64) error {
+// GetDrafts returns a page of userID's draft and scheduled posts, newest
+// first
+func (s *PostStore) GetDrafts(ctx context.Context, userID int64, cq PaginatedCursorQuery) ([]Post, string, error) {
 	query := `
-		DELETE FROM posts WHERE id = $1
-	`
+		SELECT p.id, p.user_id, p.title, p.content, p.created_at, p.updated_at, p.tags, p.version, p.status, p.publish_at
+		FROM posts p
+		WHERE p.user_id = $1 AND p.status <> 'published'` + livePostsCondition("p")
+
+	args := []interface{}{userID}
+
+	if cq.Cursor != "" {
+		cursor, err := DecodeCursor(cq.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		query += ` AND (p.created_at, p.id) < ($2, $3)`
+		args = append(args, cursor.CreatedAt, cursor.ID)
+	}
+
+	// Fetch one extra row to know whether another page exists
+	query += `
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT $` + strconv.Itoa(len(args)+1)
+	args = append(args, cq.Limit+1)
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	res, err := s.db.ExecContext(ctx, query, postID)
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return err
+		return nil, "", err
 	}
+	defer rows.Close()
 
-	rows, err := res.RowsAffected()
+	posts, err := scanPosts(rows)
 	if err != nil {
-		return err
+		return nil, "", err
 	}
 
-	if rows == 0 {
-		return ErrorNotFound
+	var next string
+	if len(posts) > cq.Limit {
+		posts = posts[:cq.Limit]
+		next = posts[len(posts)-1].cursor().Encode()
 	}
 
-	return nil
+	return posts, next, nil
 }
 
-func (s *PostStore) Update(ctx context.Context, post *Post) error {
+// PublishDue publishes up to limit scheduled posts whose publish_at has
+// passed and returns them. Rows are claimed with FOR UPDATE SKIP LOCKED so
+// that concurrent schedulers never publish the same post twice. Publishing
+// bumps the version and records a revision, so that edits made against the
+// scheduled version fail their version check.
+func (s *PostStore) PublishDue(ctx context.Context, limit int) ([]Post, error) {
+	var posts []Post
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			WITH due AS (
+				SELECT id FROM posts
+				WHERE status = 'scheduled' AND publish_at <= NOW() AND deleted_at IS NULL
+				ORDER BY publish_at, id
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+			UPDATE posts p
+			SET status = 'published', created_at = NOW(), version = version + 1, updated_at = NOW()
+			FROM due
+			WHERE p.id = due.id
+			RETURNING p.id, p.user_id, p.title, p.content, p.created_at, p.updated_at, p.tags, p.version, p.status, p.publish_at
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		rows, err := tx.QueryContext(ctx, query, limit)
+		if err != nil {
+			return err
+		}
+
+		posts, err = scanPosts(rows)
+		rows.Close()
+		if err != nil {
+			return err
+		}
+
+		for i := range posts {
+			if err := recordRevision(ctx, tx, &posts[i]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+// scanPosts scans rows selecting the columns of a Post, up to publish_at
+func scanPosts(rows *sql.Rows) ([]Post, error) {
+	posts := make([]Post, 0)
+	for rows.Next() {
+		var post Post
+		err := rows.Scan(
+			&post.ID,
+			&post.UserID,
+			&post.Title,
+			&post.Content,
+			&post.CreatedAt,
+			&post.UpdatedAt,
+			pq.Array(&post.Tags),
+			&post.Version,
+			&post.Status,
+			&post.PublishAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+// PurgeTrash permanently deletes up to limit posts that were moved to the
+// trash before the given time, along with their comments, reactions and
+// revisions. It returns the number of posts deleted.
+func (s *PostStore) PurgeTrash(ctx context.Context, before time.Time, limit int) (int64, error) {
 	query := `
-		UPDATE posts
-		SET title = $1, content = $2, version = version + 1, updated_at = NOW()
-		WHERE id = $3 AND version = $4
-		RETURNING version, updated_at
+		DELETE FROM posts
+		WHERE id IN (
+			SELECT id FROM posts
+			WHERE deleted_at < $1
+			ORDER BY deleted_at, id
+			LIMIT $2
+		)
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	err := s.db.QueryRowContext(
-		ctx,
-		query,
-		post.Title,
-		post.Content,
-		post.ID,
-		post.Version,
-	).Scan(
-		&post.Version,
-		&post.UpdatedAt,
-	)
-
+	res, err := s.db.ExecContext(ctx, query, before, limit)
 	if err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			return ErrorNotFound
-		default:
-			return err
-		}
+		return 0, err
 	}
 
-	return nil
+	return res.RowsAffected()
+}
+
+// Update saves the title, content and status of post if it is still at the
+// version it was read at, and records the new version as a revision. It
+// returns ErrorNotFound when the post was deleted or edited in the meantime.
+// Publishing a draft dates the post from its publication, and published
+// posts stay published.
+func (s *PostStore) Update(ctx context.Context, post *Post) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			UPDATE posts
+			SET title = $1, content = $2, version = version + 1, updated_at = NOW(),
+				created_at = CASE WHEN status <> 'published' AND $5::VARCHAR = 'published' THEN NOW() ELSE created_at END,
+				publish_at = CASE WHEN status = 'published' THEN publish_at ELSE $6 END,
+				status = CASE WHEN status = 'published' THEN status ELSE $5 END
+			WHERE id = $3 AND version = $4
+			RETURNING version, updated_at, created_at, status, publish_at
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			post.Title,
+			post.Content,
+			post.ID,
+			post.Version,
+			post.Status,
+			post.PublishAt,
+		).Scan(
+			&post.Version,
+			&post.UpdatedAt,
+			&post.CreatedAt,
+			&post.Status,
+			&post.PublishAt,
+		)
+
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrorNotFound
+			default:
+				return err
+			}
+		}
+
+		return recordRevision(ctx, tx, post)
+	})
 }
@@ -0,0 +1,148 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// ReactionKinds are the reactions a post accepts, kept in sync with the
+// check constraint on post_reactions.kind
+var ReactionKinds = []string{"like", "love", "laugh", "wow", "sad", "angry"}
+
+// ReactionCounts maps a reaction kind to how many users reacted with it.
+// Kinds nobody used are left out.
+type ReactionCounts map[string]int
+
+// Scan decodes the JSON object built by reactionsColumns
+func (c *ReactionCounts) Scan(src any) error {
+	var data []byte
+	switch v := src.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	case nil:
+		*c = ReactionCounts{}
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into ReactionCounts", src)
+	}
+
+	counts := ReactionCounts{}
+	if err := json.Unmarshal(data, &counts); err != nil {
+		return err
+	}
+	*c = counts
+	return nil
+}
+
+// Total returns the number of reactions of every kind
+func (c ReactionCounts) Total() int {
+	total := 0
+	for _, n := range c {
+		total += n
+	}
+	return total
+}
+
+// PostReactions summarizes the reactions to a post as seen by a viewer
+type PostReactions struct {
+	Reactions   ReactionCounts `json:"reactions"`
+	MyReactions []string       `json:"my_reactions"`
+}
+
+// reactionsColumns returns the select list of a PostReactions for the post
+// whose ID is in column, with the viewer's ID bound to viewerParam
+func reactionsColumns(column, viewerParam string) string {
+	return `
+			COALESCE((
+				SELECT jsonb_object_agg(rc.kind, rc.count)
+				FROM (
+					SELECT r.kind, COUNT(*) AS count
+					FROM post_reactions r
+					WHERE r.post_id = ` + column + `
+					GROUP BY r.kind
+				) rc
+			), '{}') AS reactions,
+			ARRAY(
+				SELECT r.kind FROM post_reactions r
+				WHERE r.post_id = ` + column + ` AND r.user_id = ` + viewerParam + `
+				ORDER BY r.kind
+			) AS my_reactions`
+}
+
+type ReactionStore struct {
+	db *sql.DB
+}
+
+// Add records userID reacting to postID with kind.
+// Reacting twice with the same kind is a no-op.
+func (s *ReactionStore) Add(ctx context.Context, postID, userID int64, kind string) error {
+	query := `
+		INSERT INTO post_reactions (post_id, user_id, kind)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, postID, userID, kind)
+	if err != nil {
+		// The post was deleted in the meantime
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return ErrorNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Remove withdraws userID's kind reaction to postID, returning ErrorNotFound
+// when there was none
+func (s *ReactionStore) Remove(ctx context.Context, postID, userID int64, kind string) error {
+	query := `DELETE FROM post_reactions WHERE post_id = $1 AND user_id = $2 AND kind = $3`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, postID, userID, kind)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrorNotFound
+	}
+
+	return nil
+}
+
+// GetByPostID returns the reactions to postID as seen by viewerID
+func (s *ReactionStore) GetByPostID(ctx context.Context, postID, viewerID int64) (*PostReactions, error) {
+	query := `SELECT ` + reactionsColumns("$1", "$2")
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	reactions := &PostReactions{}
+	err := s.db.QueryRowContext(ctx, query, postID, viewerID).Scan(
+		&reactions.Reactions,
+		pq.Array(&reactions.MyReactions),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return reactions, nil
+}
@@ -0,0 +1,162 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// RefreshToken is an opaque, hashed refresh token. Tokens issued from the same
+// login share a FamilyID so that a reused token can revoke the whole chain.
+type RefreshToken struct {
+	Token     string
+	UserID    int64
+	FamilyID  string
+	Expiry    time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+type RefreshTokenStore struct {
+	db *sql.DB
+}
+
+func (s *RefreshTokenStore) Create(ctx context.Context, token *RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (token, user_id, family_id, expiry)
+		VALUES ($1, $2, $3, $4)
+		RETURNING created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(ctx, query, token.Token, token.UserID, token.FamilyID, token.Expiry).Scan(&token.CreatedAt)
+}
+
+// Rotate exchanges the refresh token identified by hashToken for next.
+// The old token is marked as revoked and the new one joins its family.
+// Presenting a token that was already rotated or revoked revokes the whole
+// family and returns ErrorTokenReused.
+func (s *RefreshTokenStore) Rotate(ctx context.Context, hashToken string, next *RefreshToken) error {
+	reused := false
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		current, err := s.getForUpdate(ctx, tx, hashToken)
+		if err != nil {
+			return err
+		}
+
+		if current.RevokedAt != nil {
+			reused = true
+			return s.revokeFamily(ctx, tx, current.FamilyID)
+		}
+
+		if time.Now().After(current.Expiry) {
+			return ErrorNotFound
+		}
+
+		next.UserID = current.UserID
+		next.FamilyID = current.FamilyID
+
+		query := `
+			UPDATE refresh_tokens SET revoked_at = NOW(), replaced_by = $1
+			WHERE token = $2
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		if _, err := tx.ExecContext(ctx, query, next.Token, current.Token); err != nil {
+			return err
+		}
+
+		query = `
+			INSERT INTO refresh_tokens (token, user_id, family_id, expiry)
+			VALUES ($1, $2, $3, $4)
+			RETURNING created_at
+		`
+
+		return tx.QueryRowContext(ctx, query, next.Token, next.UserID, next.FamilyID, next.Expiry).Scan(&next.CreatedAt)
+	})
+	if err != nil {
+		return err
+	}
+
+	if reused {
+		return ErrorTokenReused
+	}
+
+	return nil
+}
+
+// Revoke revokes the family the given refresh token belongs to.
+func (s *RefreshTokenStore) Revoke(ctx context.Context, hashToken string) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		current, err := s.getForUpdate(ctx, tx, hashToken)
+		if err != nil {
+			return err
+		}
+
+		return s.revokeFamily(ctx, tx, current.FamilyID)
+	})
+}
+
+func (s *RefreshTokenStore) getForUpdate(ctx context.Context, tx *sql.Tx, hashToken string) (*RefreshToken, error) {
+	query := `
+		SELECT token, user_id, family_id, expiry, revoked_at, created_at
+		FROM refresh_tokens
+		WHERE token = $1
+		FOR UPDATE
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	token := &RefreshToken{}
+	err := tx.QueryRowContext(ctx, query, hashToken).Scan(
+		&token.Token,
+		&token.UserID,
+		&token.FamilyID,
+		&token.Expiry,
+		&token.RevokedAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrorNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return token, nil
+}
+
+func (s *RefreshTokenStore) revokeFamily(ctx context.Context, tx *sql.Tx, familyID string) error {
+	query := `
+		UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE family_id = $1 AND revoked_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, familyID)
+	return err
+}
+
+// revokeUserRefreshTokens signs the user out of every session
+func revokeUserRefreshTokens(ctx context.Context, tx *sql.Tx, userID int64) error {
+	query := `
+		UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userID)
+	return err
+}
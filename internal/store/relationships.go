@@ -0,0 +1,207 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+// hiddenUsersCondition returns a condition filtering out rows whose column
+// holds a user the viewer muted or blocked, or who blocked the viewer. The
+// viewer's ID is bound to the viewerParam placeholder (e.g. "$1").
+func hiddenUsersCondition(column, viewerParam string) string {
+	return `
+		AND NOT EXISTS (SELECT 1 FROM mutes m WHERE m.muter_id = ` + viewerParam + ` AND m.muted_id = ` + column + `)` +
+		blockedUsersCondition(column, viewerParam)
+}
+
+// blockedUsersCondition returns a condition filtering out rows whose column
+// holds a user the viewer blocked or who blocked the viewer
+func blockedUsersCondition(column, viewerParam string) string {
+	return `
+		AND NOT EXISTS (
+			SELECT 1 FROM blocks b
+			WHERE (b.blocker_id = ` + viewerParam + ` AND b.blocked_id = ` + column + `)
+				OR (b.blocker_id = ` + column + ` AND b.blocked_id = ` + viewerParam + `)
+		)`
+}
+
+// visibleAuthorsCondition returns a condition keeping rows whose column holds
+// a public account, the viewer, or a private account the viewer follows
+func visibleAuthorsCondition(column, viewerParam string) string {
+	return `
+		AND (
+			` + column + ` = ` + viewerParam + `
+			OR NOT EXISTS (SELECT 1 FROM users pu WHERE pu.id = ` + column + ` AND pu.is_private)
+			OR EXISTS (SELECT 1 FROM followers vf WHERE vf.user_id = ` + column + ` AND vf.follower_id = ` + viewerParam + `)
+		)`
+}
+
+// Relationship describes how a viewer relates to another user
+type Relationship struct {
+	Following  bool `json:"following"`
+	Requested  bool `json:"requested"` // the viewer asked to follow the private account
+	FollowedBy bool `json:"followed_by"`
+	Blocking   bool `json:"blocking"`
+	BlockedBy  bool `json:"-"`
+	Muting     bool `json:"muting"`
+}
+
+type RelationshipStore struct {
+	db *sql.DB
+}
+
+// Get returns how viewerID relates to userID
+func (s *RelationshipStore) Get(ctx context.Context, viewerID, userID int64) (*Relationship, error) {
+	query := `
+		SELECT
+			EXISTS (SELECT 1 FROM followers WHERE user_id = $2 AND follower_id = $1),
+			EXISTS (SELECT 1 FROM follow_requests WHERE user_id = $2 AND requester_id = $1),
+			EXISTS (SELECT 1 FROM followers WHERE user_id = $1 AND follower_id = $2),
+			EXISTS (SELECT 1 FROM blocks WHERE blocker_id = $1 AND blocked_id = $2),
+			EXISTS (SELECT 1 FROM blocks WHERE blocker_id = $2 AND blocked_id = $1),
+			EXISTS (SELECT 1 FROM mutes WHERE muter_id = $1 AND muted_id = $2)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rel := &Relationship{}
+	err := s.db.QueryRowContext(ctx, query, viewerID, userID).Scan(
+		&rel.Following,
+		&rel.Requested,
+		&rel.FollowedBy,
+		&rel.Blocking,
+		&rel.BlockedBy,
+		&rel.Muting,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return rel, nil
+}
+
+// Block makes blockerID block blockedID, removing the follows and follow
+// requests between them in both directions along with the posts they fanned
+// out to each other.
+// Blocking an already blocked user is a no-op.
+func (s *RelationshipStore) Block(ctx context.Context, blockerID, blockedID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `
+			INSERT INTO blocks (blocker_id, blocked_id)
+			VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`
+		if _, err := tx.ExecContext(ctx, query, blockerID, blockedID); err != nil {
+			return err
+		}
+
+		query = `
+			DELETE FROM followers
+			WHERE (user_id = $1 AND follower_id = $2) OR (user_id = $2 AND follower_id = $1)
+		`
+		if _, err := tx.ExecContext(ctx, query, blockerID, blockedID); err != nil {
+			return err
+		}
+
+		query = `
+			DELETE FROM follow_requests
+			WHERE (user_id = $1 AND requester_id = $2) OR (user_id = $2 AND requester_id = $1)
+		`
+		if _, err := tx.ExecContext(ctx, query, blockerID, blockedID); err != nil {
+			return err
+		}
+
+		if err := clearTimeline(ctx, tx, blockerID, blockedID); err != nil {
+			return err
+		}
+		return clearTimeline(ctx, tx, blockedID, blockerID)
+	})
+}
+
+func (s *RelationshipStore) Unblock(ctx context.Context, blockerID, blockedID int64) error {
+	query := `DELETE FROM blocks WHERE blocker_id = $1 AND blocked_id = $2`
+	return s.delete(ctx, query, blockerID, blockedID)
+}
+
+// Mute hides mutedID's posts and comments from muterID without them knowing.
+// Muting an already muted user is a no-op.
+func (s *RelationshipStore) Mute(ctx context.Context, muterID, mutedID int64) error {
+	query := `
+		INSERT INTO mutes (muter_id, muted_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, muterID, mutedID)
+	return err
+}
+
+func (s *RelationshipStore) Unmute(ctx context.Context, muterID, mutedID int64) error {
+	query := `DELETE FROM mutes WHERE muter_id = $1 AND muted_id = $2`
+	return s.delete(ctx, query, muterID, mutedID)
+}
+
+// delete runs a DELETE query, returning ErrorNotFound when no row matched
+func (s *RelationshipStore) delete(ctx context.Context, query string, args ...any) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrorNotFound
+	}
+
+	return nil
+}
+
+// CanViewPosts reports whether viewerID may see authorID's posts: neither
+// blocked the other, and the author's account is public, is the viewer's own
+// or is followed by the viewer
+func (s *RelationshipStore) CanViewPosts(ctx context.Context, viewerID, authorID int64) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM users u
+			WHERE u.id = $2` + blockedUsersCondition("u.id", "$1") + visibleAuthorsCondition("u.id", "$1") + `
+		)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var visible bool
+	err := s.db.QueryRowContext(ctx, query, viewerID, authorID).Scan(&visible)
+	return visible, err
+}
+
+// isBlocked reports whether either user blocked the other
+func isBlocked(ctx context.Context, tx *sql.Tx, userID, otherID int64) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM blocks
+			WHERE (blocker_id = $1 AND blocked_id = $2) OR (blocker_id = $2 AND blocked_id = $1)
+		)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var blocked bool
+	err := tx.QueryRowContext(ctx, query, userID, otherID).Scan(&blocked)
+	return blocked, err
+}
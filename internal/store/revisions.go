@@ -0,0 +1,142 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// PostRevision is a snapshot of a post at one of its versions
+type PostRevision struct {
+	PostID    int64     `json:"post_id"`
+	Version   int       `json:"version"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	Tags      []string  `json:"tags"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// cursor returns the keyset position of the revision, versions stand in
+// for IDs
+func (r PostRevision) cursor() Cursor {
+	return Cursor{CreatedAt: r.CreatedAt, ID: int64(r.Version)}
+}
+
+// recordRevision snapshots post at its current version
+func recordRevision(ctx context.Context, tx *sql.Tx, post *Post) error {
+	query := `
+		INSERT INTO post_revisions (post_id, version, title, content, tags, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(
+		ctx,
+		query,
+		post.ID,
+		post.Version,
+		post.Title,
+		post.Content,
+		pq.Array(post.Tags),
+		post.UpdatedAt,
+	)
+	return err
+}
+
+type RevisionStore struct {
+	db *sql.DB
+}
+
+// List returns a page of the revisions of postID, newest version first
+func (s *RevisionStore) List(ctx context.Context, postID int64, cq PaginatedCursorQuery) ([]PostRevision, string, error) {
+	query := `
+		SELECT post_id, version, title, content, tags, created_at
+		FROM post_revisions
+		WHERE post_id = $1`
+
+	args := []interface{}{postID}
+
+	if cq.Cursor != "" {
+		cursor, err := DecodeCursor(cq.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		query += ` AND version < $2`
+		args = append(args, cursor.ID)
+	}
+
+	// Fetch one extra row to know whether another page exists
+	query += `
+		ORDER BY version DESC
+		LIMIT $` + strconv.Itoa(len(args)+1)
+	args = append(args, cq.Limit+1)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	revisions := make([]PostRevision, 0, cq.Limit)
+	for rows.Next() {
+		var rev PostRevision
+		err := rows.Scan(&rev.PostID, &rev.Version, &rev.Title, &rev.Content, pq.Array(&rev.Tags), &rev.CreatedAt)
+		if err != nil {
+			return nil, "", err
+		}
+		revisions = append(revisions, rev)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	var next string
+	if len(revisions) > cq.Limit {
+		revisions = revisions[:cq.Limit]
+		next = revisions[len(revisions)-1].cursor().Encode()
+	}
+
+	return revisions, next, nil
+}
+
+// Get returns the revision of postID at version
+func (s *RevisionStore) Get(ctx context.Context, postID int64, version int) (*PostRevision, error) {
+	query := `
+		SELECT post_id, version, title, content, tags, created_at
+		FROM post_revisions
+		WHERE post_id = $1 AND version = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var rev PostRevision
+	err := s.db.QueryRowContext(ctx, query, postID, version).Scan(
+		&rev.PostID,
+		&rev.Version,
+		&rev.Title,
+		&rev.Content,
+		pq.Array(&rev.Tags),
+		&rev.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrorNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &rev, nil
+}
@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+
+	"github.com/lib/pq"
+)
+
+type Role struct {
+	ID          int64    `json:"id"`
+	Name        string   `json:"name"`
+	Level       int      `json:"level"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// HasPermission reports whether the role grants the given permission
+func (r *Role) HasPermission(permission string) bool {
+	return slices.Contains(r.Permissions, permission)
+}
+
+// rolePermissionsSubquery selects the permission names of the role aliased as "roles"
+const rolePermissionsSubquery = `ARRAY(
+			SELECT p.name FROM role_permissions rp
+			JOIN permissions p ON p.id = rp.permission_id
+			WHERE rp.role_id = roles.id
+			ORDER BY p.name
+		)`
+
+type RoleStore struct {
+	db *sql.DB
+}
+
+func (s *RoleStore) GetByName(ctx context.Context, name string) (*Role, error) {
+	query := `
+		SELECT id, name, level, description, ` + rolePermissionsSubquery + `
+		FROM roles
+		WHERE name = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	role := &Role{}
+	err := s.db.QueryRowContext(ctx, query, name).Scan(
+		&role.ID,
+		&role.Name,
+		&role.Level,
+		&role.Description,
+		pq.Array(&role.Permissions),
+	)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrorNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return role, nil
+}
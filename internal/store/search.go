@@ -0,0 +1,267 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+const (
+	SearchTypePosts = "posts"
+	SearchTypeUsers = "users"
+	SearchTypeTags  = "tags"
+)
+
+// SearchQuery is a global search request. Posts are matched with
+// websearch_to_tsquery syntax ("quoted phrases", or, -excluded), users and
+// tags by prefix and similarity.
+type SearchQuery struct {
+	Query  string `json:"q" validate:"required,max=100"`
+	Type   string `json:"type" validate:"oneof=posts users tags"`
+	Limit  int    `json:"limit" validate:"gte=1,lte=50"`
+	Offset int    `json:"offset" validate:"gte=0"`
+}
+
+func (sq SearchQuery) Parse(r *http.Request) (SearchQuery, error) {
+	qs := r.URL.Query()
+
+	sq.Query = strings.TrimSpace(qs.Get("q"))
+
+	// Parse type with default value of "posts"
+	sq.Type = qs.Get("type")
+	if sq.Type == "" {
+		sq.Type = SearchTypePosts
+	}
+
+	// Parse limit with default value of 20
+	sq.Limit = 20
+	if limit := qs.Get("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return sq, err
+		}
+		sq.Limit = l
+	}
+
+	// Parse offset with default value of 0
+	if offset := qs.Get("offset"); offset != "" {
+		o, err := strconv.Atoi(offset)
+		if err != nil {
+			return sq, err
+		}
+		sq.Offset = o
+	}
+
+	return sq, nil
+}
+
+// SearchResults is a page of search hits. Hits holds []PostSearchHit,
+// []UserSearchHit or []TagSearchHit depending on Type.
+type SearchResults struct {
+	Query      string     `json:"query"`
+	Type       string     `json:"type"`
+	Hits       any        `json:"hits"`
+	Pagination Pagination `json:"pagination"`
+}
+
+// NewSearchResults wraps a page of hits fetched with sq
+func NewSearchResults(sq SearchQuery, hits any, hasMore bool) SearchResults {
+	offset := sq.Offset
+	return SearchResults{
+		Query: sq.Query,
+		Type:  sq.Type,
+		Hits:  hits,
+		Pagination: Pagination{
+			Limit:   sq.Limit,
+			Offset:  &offset,
+			HasMore: hasMore,
+		},
+	}
+}
+
+// PostSearchHit is a post matching a search, with the matched terms of the
+// title and an excerpt of the content wrapped in <mark> tags. Both are HTML:
+// the user-written text is escaped, only the <mark> tags are markup.
+type PostSearchHit struct {
+	Post
+	Rank           float64 `json:"rank"`
+	TitleHighlight string  `json:"title_highlight"`
+	Snippet        string  `json:"snippet"`
+}
+
+type UserSearchHit struct {
+	ID       int64   `json:"id"`
+	Username string  `json:"username"`
+	Rank     float64 `json:"rank"`
+}
+
+type TagSearchHit struct {
+	Tag   string `json:"tag"`
+	Posts int    `json:"posts"`
+}
+
+type SearchStore struct {
+	db *sql.DB
+}
+
+// SearchPosts returns posts matching sq.Query that viewerID can see, most
+// relevant first
+func (s *SearchStore) SearchPosts(ctx context.Context, viewerID int64, sq SearchQuery) ([]PostSearchHit, bool, error) {
+	// Rank and page first so that headlines are only built for the page
+	query := `
+		SELECT 
+			p.id, p.user_id, p.title, p.content, p.created_at, p.updated_at, p.tags, p.version, p.status, p.publish_at,
+			m.rank,
+			ts_headline('english', ` + escapeHTML("p.title") + `, m.q, 'HighlightAll=true, StartSel=<mark>, StopSel=</mark>'),
+			ts_headline('english', ` + escapeHTML("p.content") + `, m.q, 'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=30, MinWords=10')
+		FROM (
+			SELECT p.id, q, ts_rank(p.search_vector, q) AS rank
+			FROM posts p, websearch_to_tsquery('english', $1) q
+			WHERE p.search_vector @@ q` + publishedPostsCondition("p") + hiddenUsersCondition("p.user_id", "$4") + visibleAuthorsCondition("p.user_id", "$4") + `
+			ORDER BY rank DESC, p.created_at DESC, p.id DESC
+			LIMIT $2 OFFSET $3
+		) m
+		INNER JOIN posts p ON p.id = m.id
+		ORDER BY m.rank DESC, p.created_at DESC, p.id DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, sq.Query, sq.Limit+1, sq.Offset, viewerID)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	hits := make([]PostSearchHit, 0)
+	for rows.Next() {
+		var h PostSearchHit
+		err := rows.Scan(
+			&h.ID,
+			&h.UserID,
+			&h.Title,
+			&h.Content,
+			&h.CreatedAt,
+			&h.UpdatedAt,
+			pq.Array(&h.Tags),
+			&h.Version,
+			&h.Status,
+			&h.PublishAt,
+			&h.Rank,
+			&h.TitleHighlight,
+			&h.Snippet,
+		)
+		if err != nil {
+			return nil, false, err
+		}
+		hits = append(hits, h)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	hits, hasMore := trimPage(hits, sq.Limit)
+	return hits, hasMore, nil
+}
+
+// SearchUsers returns active users whose username starts with or resembles
+// sq.Query, closest first, leaving out users viewerID blocked or was blocked by
+func (s *SearchStore) SearchUsers(ctx context.Context, viewerID int64, sq SearchQuery) ([]UserSearchHit, bool, error) {
+	query := `
+		SELECT u.id, u.username, similarity(u.username, $1) AS rank
+		FROM users u
+		WHERE u.is_active = true AND (u.username ILIKE $2 OR u.username % $1)` + blockedUsersCondition("u.id", "$5") + `
+		ORDER BY u.username ILIKE $2 DESC, rank DESC, u.username
+		LIMIT $3 OFFSET $4
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, sq.Query, escapeLike(sq.Query)+"%", sq.Limit+1, sq.Offset, viewerID)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	hits := make([]UserSearchHit, 0)
+	for rows.Next() {
+		var h UserSearchHit
+		if err := rows.Scan(&h.ID, &h.Username, &h.Rank); err != nil {
+			return nil, false, err
+		}
+		hits = append(hits, h)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	hits, hasMore := trimPage(hits, sq.Limit)
+	return hits, hasMore, nil
+}
+
+// SearchTags returns tags starting with sq.Query, most used first, counting
+// only the posts viewerID can see
+func (s *SearchStore) SearchTags(ctx context.Context, viewerID int64, sq SearchQuery) ([]TagSearchHit, bool, error) {
+	query := `
+		SELECT tag, COUNT(*) AS posts
+		FROM posts p, unnest(p.tags) AS tag
+		WHERE tag ILIKE $1` + publishedPostsCondition("p") + hiddenUsersCondition("p.user_id", "$4") + visibleAuthorsCondition("p.user_id", "$4") + `
+		GROUP BY tag
+		ORDER BY posts DESC, tag
+		LIMIT $2 OFFSET $3
+	`
+
+	prefix := strings.TrimPrefix(sq.Query, "#")
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, escapeLike(prefix)+"%", sq.Limit+1, sq.Offset, viewerID)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	hits := make([]TagSearchHit, 0)
+	for rows.Next() {
+		var h TagSearchHit
+		if err := rows.Scan(&h.Tag, &h.Posts); err != nil {
+			return nil, false, err
+		}
+		hits = append(hits, h)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	hits, hasMore := trimPage(hits, sq.Limit)
+	return hits, hasMore, nil
+}
+
+// trimPage drops the extra row fetched to know whether another page exists
+func trimPage[T any](rows []T, limit int) ([]T, bool) {
+	if len(rows) > limit {
+		return rows[:limit], true
+	}
+	return rows, false
+}
+
+// escapeHTML returns a SQL expression escaping the HTML special characters
+// of the text column, so that ts_headline output only contains our own tags
+func escapeHTML(column string) string {
+	return `replace(replace(replace(replace(replace(` + column + `, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;'), '''', '&#39;')`
+}
+
+// escapeLike escapes the LIKE wildcards in s so that it matches literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -11,6 +11,9 @@ var (
 	ErrorNotFound        = errors.New("record not found")
 	ErrorConflict        = errors.New("resource already exists")
 	ErrorNotFollowing    = errors.New("not following user")
+	ErrorTokenReused     = errors.New("refresh token reuse detected")
+	ErrorEditConflict    = errors.New("edit conflict")
+	ErrorBlocked         = errors.New("user is blocked")
 	QueryTimeoutDuration = time.Second * 5
 )
 
@@ -19,32 +22,111 @@ type Storage struct {
 	PostsRepo    Posts
 	CommentRepo  Comments
 	FollowerRepo Followers
+	RefreshRepo  RefreshTokens
+	RolesRepo    Roles
+	OutboxRepo   Outbox
+	TimelineRepo Timelines
+	SearchRepo   Search
+	RelationRepo Relationships
+	ReactionRepo Reactions
+	RevisionRepo Revisions
 }
 
 type Posts interface {
 	Create(context.Context, *Post) error
 	GetByID(context.Context, int64) (*Post, error)
-	GetByUserID(context.Context, int64) ([]Post, error)
-	Delete(context.Context, int64) error
+	GetByUserID(context.Context, int64, PaginatedFeedQuery) ([]Post, PageCursors, error)
+	Delete(ctx context.Context, postID int64, version int) error
+	GetTrashedByID(context.Context, int64) (*Post, error)
+	Restore(context.Context, *Post) error
+	GetTrash(ctx context.Context, userID int64, cq PaginatedCursorQuery) ([]Post, string, error)
+	PurgeTrash(ctx context.Context, before time.Time, limit int) (int64, error)
+	GetDrafts(ctx context.Context, userID int64, cq PaginatedCursorQuery) ([]Post, string, error)
+	PublishDue(ctx context.Context, limit int) ([]Post, error)
 	Update(context.Context, *Post) error
-	GetUserFeed(context.Context, int64, PaginatedFeedQuery) ([]PostsWithMetaData, error)
+	GetUserFeed(context.Context, int64, PaginatedFeedQuery) ([]PostsWithMetaData, PageCursors, error)
+	GetAuthorInteractions(ctx context.Context, viewerID int64, since time.Time) (map[int64]int, error)
 }
 
 type Users interface {
 	Create(context.Context, *User) error
 	GetByID(context.Context, int64) (*User, error)
 	GetByEmail(context.Context, string) (*User, error)
-	CreateAndInvite(context.Context, *User, string, time.Time) error
+	GetByUsername(context.Context, string) (*User, error)
+	UpdateProfile(context.Context, *User) error
+	CreateAndInvite(context.Context, *User, string, time.Time, *OutboxMessage) error
 	Activate(context.Context, string) error
+	CreatePasswordReset(ctx context.Context, user *User, token string, exp time.Time, email *OutboxMessage) error
+	ResetPassword(ctx context.Context, token string, password *Password) error
 }
 
 type Comments interface {
-	GetByPostID(context.Context, int64) ([]Comment, error)
+	GetByPostID(ctx context.Context, postID, viewerID int64) ([]Comment, error)
+	ListByPostID(ctx context.Context, postID, viewerID int64, cq PaginatedCursorQuery) ([]Comment, string, error)
+	GetByID(context.Context, int64) (*Comment, error)
+	Create(context.Context, *Comment) error
+	Update(context.Context, *Comment) error
+	Delete(context.Context, int64) error
 }
 
 type Followers interface {
-	Follow(ctx context.Context, followerID, userID int64) error
+	Follow(ctx context.Context, followerID, userID int64) (bool, error)
 	Unfollow(ctx context.Context, followerID, userID int64) error
+	ListRequests(ctx context.Context, userID int64, cq PaginatedCursorQuery) ([]FollowEntry, string, error)
+	ApproveRequest(ctx context.Context, userID, requesterID int64) error
+	RejectRequest(ctx context.Context, userID, requesterID int64) error
+	ListFollowers(ctx context.Context, userID, viewerID int64, cq PaginatedCursorQuery) ([]FollowEntry, string, error)
+	ListFollowing(ctx context.Context, userID, viewerID int64, cq PaginatedCursorQuery) ([]FollowEntry, string, error)
+	GetCounts(context.Context, int64) (*FollowCounts, error)
+}
+
+type Relationships interface {
+	Get(ctx context.Context, viewerID, userID int64) (*Relationship, error)
+	Block(ctx context.Context, blockerID, blockedID int64) error
+	Unblock(ctx context.Context, blockerID, blockedID int64) error
+	Mute(ctx context.Context, muterID, mutedID int64) error
+	Unmute(ctx context.Context, muterID, mutedID int64) error
+	CanViewPosts(ctx context.Context, viewerID, authorID int64) (bool, error)
+}
+
+type Reactions interface {
+	Add(ctx context.Context, postID, userID int64, kind string) error
+	Remove(ctx context.Context, postID, userID int64, kind string) error
+	GetByPostID(ctx context.Context, postID, viewerID int64) (*PostReactions, error)
+}
+
+type Revisions interface {
+	List(ctx context.Context, postID int64, cq PaginatedCursorQuery) ([]PostRevision, string, error)
+	Get(ctx context.Context, postID int64, version int) (*PostRevision, error)
+}
+
+type Timelines interface {
+	FanOut(ctx context.Context, post *Post, maxFollowers int) (bool, error)
+	GetFeed(context.Context, int64, PaginatedFeedQuery) ([]PostsWithMetaData, PageCursors, error)
+}
+
+type Search interface {
+	SearchPosts(ctx context.Context, viewerID int64, sq SearchQuery) ([]PostSearchHit, bool, error)
+	SearchUsers(ctx context.Context, viewerID int64, sq SearchQuery) ([]UserSearchHit, bool, error)
+	SearchTags(context.Context, int64, SearchQuery) ([]TagSearchHit, bool, error)
+}
+
+type RefreshTokens interface {
+	Create(context.Context, *RefreshToken) error
+	Rotate(ctx context.Context, hashToken string, next *RefreshToken) error
+	Revoke(ctx context.Context, hashToken string) error
+}
+
+type Roles interface {
+	GetByName(context.Context, string) (*Role, error)
+}
+
+type Outbox interface {
+	Enqueue(context.Context, *OutboxMessage) error
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]OutboxMessage, error)
+	MarkSent(ctx context.Context, id int64) error
+	MarkFailed(ctx context.Context, id int64, reason string, retryAt time.Time) error
+	ListFailed(context.Context, PaginatedCursorQuery) ([]OutboxMessage, string, error)
 }
 
 func NewStorage(db *sql.DB) Storage {
@@ -53,6 +135,14 @@ func NewStorage(db *sql.DB) Storage {
 		UsersRepo:    &UsersStore{db: db},
 		CommentRepo:  &CommentStore{db: db},
 		FollowerRepo: &FollowerStore{db: db},
+		RefreshRepo:  &RefreshTokenStore{db: db},
+		RolesRepo:    &RoleStore{db: db},
+		OutboxRepo:   &OutboxStore{db: db},
+		TimelineRepo: &TimelineStore{db: db},
+		SearchRepo:   &SearchStore{db: db},
+		RelationRepo: &RelationshipStore{db: db},
+		ReactionRepo: &ReactionStore{db: db},
+		RevisionRepo: &RevisionStore{db: db},
 	}
 }
 
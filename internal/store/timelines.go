@@ -0,0 +1,164 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+)
+
+// timelineBackfillSize is the number of an author's latest posts copied into
+// a timeline when its owner starts following them
+const timelineBackfillSize = 100
+
+// TimelineStore maintains the materialized home timelines. Posts are pushed
+// into their author's followers' timelines when published (fan-out-on-write);
+// posts that were not fanned out are merged in when timelines are read.
+type TimelineStore struct {
+	db *sql.DB
+}
+
+// FanOut pushes a post into the timelines of its author's followers. Authors
+// with more than maxFollowers followers are skipped and their posts are
+// served from the posts table at read time; FanOut then returns false.
+func (s *TimelineStore) FanOut(ctx context.Context, post *Post, maxFollowers int) (bool, error) {
+	fannedOut := false
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var followers int
+		err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM followers WHERE user_id = $1`, post.UserID).Scan(&followers)
+		if err != nil {
+			return err
+		}
+
+		if followers > maxFollowers {
+			return nil
+		}
+
+		query := `
+			INSERT INTO timelines (user_id, post_id, author_id, created_at)
+			SELECT follower_id, $1, $2, $3
+			FROM followers
+			WHERE user_id = $2
+			ON CONFLICT DO NOTHING
+		`
+		if _, err := tx.ExecContext(ctx, query, post.ID, post.UserID, post.CreatedAt); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE posts SET fanned_out = TRUE WHERE id = $1`, post.ID); err != nil {
+			return err
+		}
+
+		fannedOut = true
+		return nil
+	})
+
+	return fannedOut, err
+}
+
+// GetFeed returns a page of userID's home timeline: the posts fanned out to
+// it, plus the posts of followed authors that were not fanned out, without
+// muted and blocked users. It takes the same filters and pagination as
+// PostStore.GetUserFeed.
+func (s *TimelineStore) GetFeed(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostsWithMetaData, PageCursors, error) {
+	args := []interface{}{userID}
+
+	// Each branch is paginated on its own index: the timeline on
+	// (user_id, created_at, post_id), followed authors' posts that were not
+	// fanned out on (user_id, created_at, id) WHERE NOT fanned_out
+	timelineConditions, args, cursor, sort, err := postsPageConditions(args, fq, "t.created_at", "t.post_id")
+	if err != nil {
+		return nil, PageCursors{}, err
+	}
+
+	pendingConditions, args, _, _, err := postsPageConditions(args, fq, "p.created_at", "p.id")
+	if err != nil {
+		return nil, PageCursors{}, err
+	}
+
+	// Each branch returns enough rows to fill the page after the offset
+	branchLimitParam := "$" + strconv.Itoa(len(args)+1)
+	limitParam := "$" + strconv.Itoa(len(args)+2)
+	offsetParam := "$" + strconv.Itoa(len(args)+3)
+	args = append(args, fq.Offset+fq.Limit+1, fq.Limit+1, fq.Offset)
+
+	query := `
+		SELECT 
+			p.id, p.user_id, p.title, p.content, p.created_at, p.updated_at, p.tags, p.version, p.status, p.publish_at,
+			(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id) AS comments_count,` + reactionsColumns("p.id", "$1") + `
+		FROM (
+			(
+				SELECT t.post_id AS id, t.created_at
+				FROM timelines t
+				INNER JOIN posts p ON p.id = t.post_id
+				WHERE t.user_id = $1` + publishedPostsCondition("p") + hiddenUsersCondition("t.author_id", "$1") + timelineConditions + `
+				ORDER BY t.created_at ` + sort + `, t.post_id ` + sort + `
+				LIMIT ` + branchLimitParam + `
+			)
+			UNION
+			(
+				SELECT p.id, p.created_at
+				FROM posts p
+				INNER JOIN followers f ON f.user_id = p.user_id
+				WHERE f.follower_id = $1 AND NOT p.fanned_out` + publishedPostsCondition("p") + hiddenUsersCondition("p.user_id", "$1") + pendingConditions + `
+				ORDER BY p.created_at ` + sort + `, p.id ` + sort + `
+				LIMIT ` + branchLimitParam + `
+			)
+		) feed
+		INNER JOIN posts p ON p.id = feed.id
+		ORDER BY feed.created_at ` + sort + `, feed.id ` + sort + `
+		LIMIT ` + limitParam + ` OFFSET ` + offsetParam
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, PageCursors{}, err
+	}
+	defer rows.Close()
+
+	feed, err := scanPostsWithMetaData(rows)
+	if err != nil {
+		return nil, PageCursors{}, err
+	}
+
+	feed, cursors := postsPage(feed, fq, cursor, PostsWithMetaData.cursor)
+	return feed, cursors, nil
+}
+
+// backfillTimeline copies the latest posts of authorID into followerID's
+// timeline. Posts still waiting to be fanned out are included so that they
+// cannot be missed by both the fan-out and the new follow, and so are posts
+// in the trash so that they reappear if restored.
+func backfillTimeline(ctx context.Context, tx *sql.Tx, followerID, authorID int64) error {
+	query := `
+		INSERT INTO timelines (user_id, post_id, author_id, created_at)
+		SELECT $1, p.id, p.user_id, p.created_at
+		FROM posts p
+		WHERE p.user_id = $2 AND p.status = 'published'
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT $3
+		ON CONFLICT DO NOTHING
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, followerID, authorID, timelineBackfillSize)
+	return err
+}
+
+// clearTimeline removes the posts of authorID from followerID's timeline
+func clearTimeline(ctx context.Context, tx *sql.Tx, followerID, authorID int64) error {
+	query := `DELETE FROM timelines WHERE user_id = $1 AND author_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, followerID, authorID)
+	return err
+}
@@ -12,12 +12,21 @@ import (
 )
 
 type User struct {
-	ID        int64    `json:"id"`
-	Username  string   `json:"username"`
-	Email     string   `json:"email"`
-	Password  Password `json:"-"`
-	IsActive  bool     `json:"is_active"`
-	CreatedAt string   `json:"created_at"`
+	ID           int64    `json:"id"`
+	Username     string   `json:"username"`
+	Email        string   `json:"-"`
+	Password     Password `json:"-"`
+	IsActive     bool     `json:"is_active"`
+	DisplayName  string   `json:"display_name"`
+	Bio          string   `json:"bio"`
+	AvatarURL    string   `json:"avatar_url"`
+	IsPrivate    bool     `json:"is_private"`
+	Version      int      `json:"version"`
+	TokenVersion int      `json:"-"` // access tokens issued with an older value are revoked
+	CreatedAt    string   `json:"created_at"`
+	UpdatedAt    string   `json:"updated_at"`
+	RoleID       int64    `json:"role_id"`
+	Role         Role     `json:"role"`
 }
 
 type Password struct {
@@ -67,6 +76,12 @@ type UsersStore struct {
 }
 
 func (s *UsersStore) Create(ctx context.Context, user *User) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return s.create(ctx, tx, user)
+	})
+}
+
+func (s *UsersStore) create(ctx context.Context, tx *sql.Tx, user *User) error {
 	query := `
 		INSERT INTO users (username, email, password)
 		VALUES ($1, $2, $3)
@@ -76,7 +91,7 @@ func (s *UsersStore) Create(ctx context.Context, user *User) error {
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	err := s.db.QueryRowContext(ctx, query, user.Username, user.Email, user.Password.Hash).Scan(
+	err := tx.QueryRowContext(ctx, query, user.Username, user.Email, user.Password.Hash).Scan(
 		&user.ID,
 		&user.CreatedAt,
 		&user.IsActive,
@@ -95,22 +110,46 @@ func (s *UsersStore) Create(ctx context.Context, user *User) error {
 }
 
 func (s *UsersStore) GetByID(ctx context.Context, id int64) (*User, error) {
+	return s.getBy(ctx, "users.id = $1", id)
+}
+
+// GetByUsername returns the active user with the given username
+func (s *UsersStore) GetByUsername(ctx context.Context, username string) (*User, error) {
+	return s.getBy(ctx, "users.username = $1 AND users.is_active = true", username)
+}
+
+func (s *UsersStore) getBy(ctx context.Context, where string, arg any) (*User, error) {
 	query := `
-		SELECT users.id, username, email, password, is_active, created_at FROM users
-		WHERE id = $1;
-	`
+		SELECT users.id, username, email, password, is_active, display_name, bio, avatar_url, is_private,
+			users.version, users.token_version, created_at, updated_at,
+			roles.id, roles.name, roles.level, roles.description, ` + rolePermissionsSubquery + `
+		FROM users
+		JOIN roles ON users.role_id = roles.id
+		WHERE ` + where
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
 	user := &User{}
-	err := s.db.QueryRowContext(ctx, query, id).Scan(
+	err := s.db.QueryRowContext(ctx, query, arg).Scan(
 		&user.ID,
 		&user.Username,
 		&user.Email,
 		&user.Password,
 		&user.IsActive,
+		&user.DisplayName,
+		&user.Bio,
+		&user.AvatarURL,
+		&user.IsPrivate,
+		&user.Version,
+		&user.TokenVersion,
 		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.Role.ID,
+		&user.Role.Name,
+		&user.Role.Level,
+		&user.Role.Description,
+		pq.Array(&user.Role.Permissions),
 	)
 	if err != nil {
 		switch err {
@@ -120,42 +159,20 @@ func (s *UsersStore) GetByID(ctx context.Context, id int64) (*User, error) {
 			return nil, err
 		}
 	}
+	user.RoleID = user.Role.ID
 
 	return user, nil
 }
 
 func (s *UsersStore) GetByEmail(ctx context.Context, email string) (*User, error) {
-	query := `
-		SELECT id, username, email, password, created_at FROM users
-		WHERE email = $1 AND is_active = true
-	`
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
-	defer cancel()
-
-	user := &User{}
-	err := s.db.QueryRowContext(ctx, query, email).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&user.Password.Hash,
-		&user.CreatedAt,
-	)
-	if err != nil {
-		switch err {
-		case sql.ErrNoRows:
-			return nil, ErrorNotFound
-		default:
-			return nil, err
-		}
-	}
-
-	return user, nil
+	return s.getBy(ctx, "users.email = $1 AND users.is_active = true", email)
 }
 
-func (s *UsersStore) CreateAndInvite(ctx context.Context, user *User, token string, exp time.Time) error {
+// CreateAndInvite creates the user and its invitation token, and queues the
+// invitation email, all in one transaction.
+func (s *UsersStore) CreateAndInvite(ctx context.Context, user *User, token string, exp time.Time, email *OutboxMessage) error {
 	return withTx(s.db, ctx, func(tx *sql.Tx) error {
-		// Create the user using the existing Create method logic
-		if err := s.Create(ctx, user); err != nil {
+		if err := s.create(ctx, tx, user); err != nil {
 			return err
 		}
 
@@ -163,7 +180,10 @@ func (s *UsersStore) CreateAndInvite(ctx context.Context, user *User, token stri
 		if err := s.createUserInvitation(ctx, tx, token, exp, user.ID); err != nil {
 			return err
 		}
-		return nil
+
+		// Queue the invitation email
+		email.Recipient = user.Email
+		return enqueueEmail(ctx, tx, email)
 	})
 }
 
@@ -251,6 +271,44 @@ func (s *UsersStore) update(ctx context.Context, tx *sql.Tx, user *User) error {
 	return nil
 }
 
+// UpdateProfile saves the user's profile fields if the user is still at the
+// version it was read at, returning ErrorEditConflict otherwise
+func (s *UsersStore) UpdateProfile(ctx context.Context, user *User) error {
+	query := `
+		UPDATE users
+		SET display_name = $1, bio = $2, avatar_url = $3, is_private = $4, version = version + 1, updated_at = NOW()
+		WHERE id = $5 AND version = $6
+		RETURNING version, updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(
+		ctx,
+		query,
+		user.DisplayName,
+		user.Bio,
+		user.AvatarURL,
+		user.IsPrivate,
+		user.ID,
+		user.Version,
+	).Scan(
+		&user.Version,
+		&user.UpdatedAt,
+	)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return ErrorEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (s *UsersStore) deleteUserInvitations(ctx context.Context, tx *sql.Tx, userID int64) error {
 	query := `DELETE FROM user_invitations WHERE user_id = $1`
 
@@ -264,3 +322,105 @@ func (s *UsersStore) deleteUserInvitations(ctx context.Context, tx *sql.Tx, user
 
 	return nil
 }
+
+// CreatePasswordReset stores a hashed password reset token for the user,
+// replacing any reset the user requested before, and queues the reset email.
+func (s *UsersStore) CreatePasswordReset(ctx context.Context, user *User, token string, exp time.Time, email *OutboxMessage) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.deletePasswordResets(ctx, tx, user.ID); err != nil {
+			return err
+		}
+
+		query := `
+			INSERT INTO password_resets (token, user_id, expiry)
+			VALUES ($1, $2, $3)
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		if _, err := tx.ExecContext(ctx, query, token, user.ID, exp); err != nil {
+			return err
+		}
+
+		email.Recipient = user.Email
+		return enqueueEmail(ctx, tx, email)
+	})
+}
+
+// ResetPassword consumes the password reset token, stores the new password
+// and revokes all of the user's refresh and access tokens.
+func (s *UsersStore) ResetPassword(ctx context.Context, token string, password *Password) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		user, err := s.getUserFromPasswordReset(ctx, tx, token)
+		if err != nil {
+			return err
+		}
+
+		if err := s.updatePassword(ctx, tx, user.ID, password); err != nil {
+			return err
+		}
+
+		if err := s.deletePasswordResets(ctx, tx, user.ID); err != nil {
+			return err
+		}
+
+		return revokeUserRefreshTokens(ctx, tx, user.ID)
+	})
+}
+
+func (s *UsersStore) getUserFromPasswordReset(ctx context.Context, tx *sql.Tx, token string) (*User, error) {
+	query := `
+		SELECT u.id, u.username, u.email, u.created_at, u.is_active
+		FROM users u
+		JOIN password_resets pr ON u.id = pr.user_id
+		WHERE pr.token = $1 AND pr.expiry > $2
+		FOR UPDATE OF pr
+	`
+
+	hash := sha256.Sum256([]byte(token))
+	hashToken := hex.EncodeToString(hash[:])
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	user := &User{}
+	err := tx.QueryRowContext(ctx, query, hashToken, time.Now()).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.CreatedAt,
+		&user.IsActive,
+	)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrorNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+func (s *UsersStore) updatePassword(ctx context.Context, tx *sql.Tx, userID int64, password *Password) error {
+	// Bumping token_version revokes the access tokens issued before
+	query := `UPDATE users SET password = $1, token_version = token_version + 1 WHERE id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, password.Hash, userID)
+	return err
+}
+
+func (s *UsersStore) deletePasswordResets(ctx context.Context, tx *sql.Tx, userID int64) error {
+	query := `DELETE FROM password_resets WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userID)
+	return err
+}